@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// AtomicCounter has the same methods as SafeCounter, but keeps one
+// atomic.Int64 per key. The key -> counter index is a sync.Map, which is
+// built for read-mostly use: once a key exists, Inc finds its counter and
+// bumps it without taking any lock. Only the first Inc of a key pays to
+// store it. BenchmarkCounterHotKeys and BenchmarkCounterNewKeys compare it
+// with SafeCounter.
+//
+// Every method acts on its key in one atomic step, like SafeCounter's do. An
+// Inc that races with Delete of the same key counts either before the Delete,
+// and is deleted with it, or after it. Reset swaps in a new, empty index, so
+// it also clears every key at once.
+//
+// The one difference is Snapshot: it reads each key on its own, so while
+// increments are running it is not one consistent view across keys.
+type AtomicCounter struct {
+	// index is nil until first use, so the zero value is ready to use.
+	index atomic.Pointer[sync.Map] // string -> *atomic.Int64
+}
+
+// counters returns the current index, creating it if needed.
+func (counter *AtomicCounter) counters() *sync.Map {
+	if index := counter.index.Load(); index != nil {
+		return index
+	}
+	counter.index.CompareAndSwap(nil, new(sync.Map))
+	return counter.index.Load()
+}
+
+// counter returns the counter for key, creating it if needed.
+func (counter *AtomicCounter) counter(key string) *atomic.Int64 {
+	counters := counter.counters()
+	if value, ok := counters.Load(key); ok {
+		return value.(*atomic.Int64)
+	}
+	value, _ := counters.LoadOrStore(key, new(atomic.Int64))
+	return value.(*atomic.Int64)
+}
+
+// Inc increments the counter for the given key.
+func (counter *AtomicCounter) Inc(key string) {
+	counter.counter(key).Add(1)
+}
+
+// Add adds n to the counter for the given key. n may be negative.
+func (counter *AtomicCounter) Add(key string, n int) {
+	counter.counter(key).Add(int64(n))
+}
+
+// Value returns the current value of the counter for the given key.
+func (counter *AtomicCounter) Value(key string) int {
+	if value, ok := counter.counters().Load(key); ok {
+		return int(value.(*atomic.Int64).Load())
+	}
+	return 0
+}
+
+// Delete removes the given key. An Inc that already found the key's counter
+// may still add to it after this, but nothing reads that counter any more,
+// so that Inc counts as happening before the Delete.
+func (counter *AtomicCounter) Delete(key string) {
+	counter.counters().Delete(key)
+}
+
+// Reset removes every key at once.
+func (counter *AtomicCounter) Reset() {
+	counter.index.Store(new(sync.Map))
+}
+
+// Snapshot returns a copy of every counter. Each key is read on its own, see
+// AtomicCounter.
+func (counter *AtomicCounter) Snapshot() map[string]int {
+	snapshot := make(map[string]int)
+	counter.counters().Range(func(key, value interface{}) bool {
+		snapshot[key.(string)] = int(value.(*atomic.Int64).Load())
+		return true
+	})
+	return snapshot
+}
+
+// TopK returns the k keys with the highest values, highest first.
+func (counter *AtomicCounter) TopK(k int) []CounterEntry {
+	return topK(counter.Snapshot(), k)
+}
+
+// WriteText writes one "key value" line per key, sorted by key.
+func (counter *AtomicCounter) WriteText(w io.Writer) error {
+	return writeCounterText(w, counter.Snapshot())
+}
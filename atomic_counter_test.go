@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkCounterHotKeys has every goroutine Inc the same 4 keys, like
+// counting crawl outcomes. SafeCounter and ShardedSafeCounter take a mutex
+// per Inc; AtomicCounter only does an atomic add.
+func BenchmarkCounterHotKeys(b *testing.B) {
+	keys := []string{"fetched", "cache_hit", "filtered", "error"}
+	for _, counterType := range counterTypes {
+		b.Run(counterType.name, func(b *testing.B) {
+			counter := counterType.new()
+			benchParallel(b, func(i int) { counter.Inc(keys[i%len(keys)]) })
+		})
+	}
+}
+
+// BenchmarkCounterNewKeys has every Inc go to a key nobody used before, where
+// AtomicCounter has to allocate a counter and store it in the sync.Map.
+func BenchmarkCounterNewKeys(b *testing.B) {
+	keys := make([]string, 1<<20)
+	for i := range keys {
+		keys[i] = fmt.Sprint("https://golang.org/", i)
+	}
+	for _, counterType := range counterTypes {
+		b.Run(counterType.name, func(b *testing.B) {
+			counter := counterType.new()
+			benchParallel(b, func(i int) {
+				if i%len(keys) == 0 {
+					// Out of new keys, start over.
+					counter.Reset()
+				}
+				counter.Inc(keys[i%len(keys)])
+			})
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Message is what gophers publish to a Broker, instead of a preformatted
+// "Message %d from %s says: %s" string.
+type Message struct {
+	Topic string
+	From  string
+	// Seq numbers the messages of one sender, starting at 0.
+	Seq  int
+	Text string
+}
+
+func (m Message) String() string {
+	return fmt.Sprintf("[%s] Message %d from %s says: %s", m.Topic, m.Seq, m.From, m.Text)
+}
+
+// SlowConsumerPolicy is what Publish does when a subscriber's buffer is full.
+type SlowConsumerPolicy int
+
+const (
+	// Block waits for the subscriber to make room, so nothing is lost, but
+	// one slow subscriber slows down every publisher.
+	Block SlowConsumerPolicy = iota
+	// DropOldest throws away the oldest buffered message to make room.
+	DropOldest
+	// DropNewest throws away the message being published.
+	DropNewest
+)
+
+// ErrBrokerClosed is returned by Publish after Close.
+var ErrBrokerClosed = fmt.Errorf("broker closed")
+
+// Subscription receives the messages of one topic on C. C is closed by
+// Unsubscribe, or by closing the Broker.
+type Subscription struct {
+	C <-chan Message
+
+	c      chan Message
+	topic  string
+	policy SlowConsumerPolicy
+	// done is closed to release publishers blocked on this subscription.
+	done     chan struct{}
+	doneOnce sync.Once
+	dropped  atomic.Int64
+}
+
+// Dropped returns how many messages were thrown away because the
+// subscription was slow.
+func (sub *Subscription) Dropped() int {
+	return int(sub.dropped.Load())
+}
+
+// deliver sends msg according to the policy. Callers hold the broker's read
+// lock, so c is not closed underneath it. A blocked send gives up when the
+// subscription or the whole broker (closing) is shutting down, or when the
+// publisher gives up (cancel).
+func (sub *Subscription) deliver(msg Message, closing, cancel <-chan struct{}) {
+	switch sub.policy {
+	case Block:
+		select {
+		case sub.c <- msg:
+		case <-sub.done:
+		case <-closing:
+		case <-cancel:
+		}
+	case DropNewest:
+		select {
+		case sub.c <- msg:
+		default:
+			sub.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case sub.c <- msg:
+				return
+			default:
+			}
+			// Full. The reader may empty it first, so don't block here either.
+			select {
+			case <-sub.c:
+				sub.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+// Broker is an in-process, topic based pub/sub. Every subscriber of a topic
+// gets its own copy of each message published to it, through its own buffer.
+type Broker struct {
+	// Publish takes the read lock, so publishers don't wait on each other.
+	// Subscribe, Unsubscribe and Close take the write lock.
+	mux    sync.RWMutex
+	topics map[string]map[*Subscription]bool
+	closed bool
+	// closing is closed first thing in Close, to release blocked publishers.
+	closing     chan struct{}
+	closingOnce sync.Once
+}
+
+// NewBroker returns a Broker with no subscribers.
+func NewBroker() *Broker {
+	return &Broker{
+		topics:  make(map[string]map[*Subscription]bool),
+		closing: make(chan struct{}),
+	}
+}
+
+// Subscribe returns a subscription to topic with room for buffer messages.
+// After Close it returns a subscription whose C is already closed.
+func (b *Broker) Subscribe(topic string, buffer int, policy SlowConsumerPolicy) *Subscription {
+	if policy != Block && buffer < 1 {
+		// Dropping only makes sense with a buffer to drop from.
+		buffer = 1
+	}
+	c := make(chan Message, buffer)
+	sub := &Subscription{C: c, c: c, topic: topic, policy: policy, done: make(chan struct{})}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.closed {
+		close(c)
+		return sub
+	}
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[*Subscription]bool)
+	}
+	b.topics[topic][sub] = true
+	return sub
+}
+
+// Unsubscribe stops deliveries to sub and closes sub.C. Messages still in
+// the buffer can be read until then.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	// Release blocked publishers first, or they would hold the read lock
+	// that the write lock below waits for.
+	sub.doneOnce.Do(func() { close(sub.done) })
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.topics[sub.topic][sub] {
+		delete(b.topics[sub.topic], sub)
+		close(sub.c)
+	}
+}
+
+// Publish delivers msg to every subscriber of msg.Topic. With a Block
+// subscriber that doesn't read, it waits until Unsubscribe or Close; use
+// PublishContext to give up sooner.
+func (b *Broker) Publish(msg Message) error {
+	return b.PublishContext(context.Background(), msg)
+}
+
+// PublishContext is Publish, but stops waiting for Block subscribers once ctx
+// is cancelled, and then returns ctx.Err(). Subscribers it didn't get to miss
+// msg.
+func (b *Broker) PublishContext(ctx context.Context, msg Message) error {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	for sub := range b.topics[msg.Topic] {
+		if ctx.Err() != nil {
+			break
+		}
+		sub.deliver(msg, b.closing, ctx.Done())
+	}
+	return ctx.Err()
+}
+
+// Close unsubscribes everybody, and makes later Publish calls fail.
+func (b *Broker) Close() {
+	// Like in Unsubscribe, release blocked publishers before locking.
+	b.closingOnce.Do(func() { close(b.closing) })
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, subs := range b.topics {
+		for sub := range subs {
+			close(sub.c)
+		}
+	}
+	b.topics = nil
+}
+
+// talkToBroker is talkToChannel for a Broker: the gopher publishes to topic
+// until told to quit, then confirms on quit. Quitting also stops a Publish
+// that waits on a subscriber that stopped reading.
+func talkToBroker(gopher, text, topic string, b *Broker, quit chan string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-quit
+		cancel()
+	}()
+	for i := 0; ; i++ {
+		if b.PublishContext(ctx, Message{topic, gopher, i, text}) == nil {
+			select {
+			case <-time.After(time.Duration(rand.Intn(1e2)) * time.Millisecond):
+				continue
+			case <-ctx.Done():
+			}
+		}
+		// Told to quit, or the broker is gone: wait for quit to say goodbye.
+		<-ctx.Done()
+		quit <- "See you!"
+		return
+	}
+}
+
+// BrokerMain has gophers chat through a Broker with two kinds of subscriber.
+func BrokerMain() {
+	broker := NewBroker()
+	// A reader that keeps up, and one that only wants the latest messages.
+	everything := broker.Subscribe("chat", 10, Block)
+	latest := broker.Subscribe("chat", 2, DropOldest)
+
+	emilyQuit, johnQuit := make(chan string), make(chan string)
+	go talkToBroker("emily", "bar", "chat", broker, emilyQuit)
+	go talkToBroker("john", "foo", "chat", broker, johnQuit)
+	for i := 0; i < 10; i++ {
+		fmt.Println(<-everything.C)
+	}
+	stopTalking(emilyQuit)
+	stopTalking(johnQuit)
+
+	broker.Close()
+	// Closing the broker closed latest.C too, after its last two messages.
+	for msg := range latest.C {
+		fmt.Println("latest:", msg)
+	}
+	fmt.Println("dropped for latest:", latest.Dropped())
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// texts drains what is buffered for sub, without waiting for more.
+func texts(sub *Subscription) []string {
+	var got []string
+	for {
+		select {
+		case msg, ok := <-sub.C:
+			if !ok {
+				return got
+			}
+			got = append(got, msg.Text)
+		default:
+			return got
+		}
+	}
+}
+
+func TestSlowConsumerPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  SlowConsumerPolicy
+		buffer  int
+		publish int
+		want    []string
+		dropped int
+	}{
+		{"Block with room", Block, 5, 3, []string{"0", "1", "2"}, 0},
+		// The publisher gives up on the rest, see PublishContext below.
+		{"Block full", Block, 2, 5, []string{"0", "1"}, 0},
+		{"DropOldest with room", DropOldest, 5, 3, []string{"0", "1", "2"}, 0},
+		{"DropOldest full keeps the newest", DropOldest, 2, 5, []string{"3", "4"}, 3},
+		{"DropOldest without a buffer gets 1", DropOldest, 0, 5, []string{"4"}, 4},
+		{"DropNewest with room", DropNewest, 5, 3, []string{"0", "1", "2"}, 0},
+		{"DropNewest full keeps the oldest", DropNewest, 2, 5, []string{"0", "1"}, 3},
+		{"DropNewest without a buffer gets 1", DropNewest, 0, 5, []string{"0"}, 4},
+	}
+	for _, test := range tests {
+		broker := NewBroker()
+		sub := broker.Subscribe("chat", test.buffer, test.policy)
+		for i := 0; i < test.publish; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			err := broker.PublishContext(ctx, Message{Topic: "chat", Seq: i, Text: fmt.Sprint(i)})
+			cancel()
+			if full := test.policy == Block && i >= test.buffer; full != (err != nil) {
+				t.Errorf("%s: Publish %d = %v", test.name, i, err)
+			}
+		}
+		if got := texts(sub); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		if got := sub.Dropped(); got != test.dropped {
+			t.Errorf("%s: Dropped() = %d, want %d", test.name, got, test.dropped)
+		}
+		broker.Close()
+	}
+}
+
+func TestBrokerTopics(t *testing.T) {
+	broker := NewBroker()
+	defer broker.Close()
+	chat1 := broker.Subscribe("chat", 10, Block)
+	chat2 := broker.Subscribe("chat", 10, DropOldest)
+	news := broker.Subscribe("news", 10, Block)
+	for _, msg := range []Message{
+		{Topic: "chat", Text: "hi"},
+		{Topic: "news", Text: "extra"},
+		{Topic: "chat", Text: "bye"},
+		// Nobody listens to this one.
+		{Topic: "sport", Text: "goal"},
+	} {
+		if err := broker.Publish(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Every subscriber of a topic gets its own copy, and only of its topic.
+	for name, test := range map[string]struct {
+		sub  *Subscription
+		want []string
+	}{
+		"chat1": {chat1, []string{"hi", "bye"}},
+		"chat2": {chat2, []string{"hi", "bye"}},
+		"news":  {news, []string{"extra"}},
+	} {
+		if got := texts(test.sub); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s got %v, want %v", name, got, test.want)
+		}
+	}
+}
+
+// closed reports whether C is closed, once anything still buffered is read.
+func closed(sub *Subscription) bool {
+	for {
+		select {
+		case _, ok := <-sub.C:
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func TestBrokerUnsubscribeAndClose(t *testing.T) {
+	broker := NewBroker()
+	stays := broker.Subscribe("chat", 10, Block)
+	leaves := broker.Subscribe("chat", 10, Block)
+	broker.Publish(Message{Topic: "chat", Text: "before"})
+
+	// What was buffered can still be read, then C is closed. Closing it a
+	// second time would panic.
+	broker.Unsubscribe(leaves)
+	broker.Unsubscribe(leaves)
+	if got := texts(leaves); !reflect.DeepEqual(got, []string{"before"}) {
+		t.Errorf("after Unsubscribe got %v, want the buffered message", got)
+	}
+	if !closed(leaves) {
+		t.Error("C still open after Unsubscribe")
+	}
+	broker.Publish(Message{Topic: "chat", Text: "after"})
+	if got := texts(stays); !reflect.DeepEqual(got, []string{"before", "after"}) {
+		t.Errorf("the other subscriber got %v", got)
+	}
+
+	broker.Close()
+	broker.Close()
+	broker.Unsubscribe(stays)
+	if !closed(stays) {
+		t.Error("C still open after Close")
+	}
+	if err := broker.Publish(Message{Topic: "chat"}); err != ErrBrokerClosed {
+		t.Errorf("Publish after Close = %v, want %v", err, ErrBrokerClosed)
+	}
+	if late := broker.Subscribe("chat", 1, Block); !closed(late) {
+		t.Error("Subscribe after Close returned an open C")
+	}
+}
+
+// TestBrokerReleasesBlockedPublisher checks that Unsubscribe and Close
+// don't wait for a Block publisher stuck on a full subscriber, and release
+// it instead.
+func TestBrokerReleasesBlockedPublisher(t *testing.T) {
+	for name, release := range map[string]func(*Broker, *Subscription){
+		"Unsubscribe": func(b *Broker, sub *Subscription) { b.Unsubscribe(sub) },
+		"Close":       func(b *Broker, sub *Subscription) { b.Close() },
+	} {
+		err := Watch(name, 5*time.Second, func() {
+			broker := NewBroker()
+			defer broker.Close()
+			full := broker.Subscribe("chat", 1, Block)
+			broker.Publish(Message{Topic: "chat", Text: "fills the buffer"})
+			published := make(chan error)
+			go func() {
+				published <- broker.Publish(Message{Topic: "chat", Text: "waits"})
+			}()
+			select {
+			case err := <-published:
+				t.Errorf("%s: Publish to a full subscriber returned %v", name, err)
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+			release(broker, full)
+			if err := <-published; err != nil {
+				t.Errorf("%s: released Publish = %v", name, err)
+			}
+			if got := texts(full); !reflect.DeepEqual(got, []string{"fills the buffer"}) {
+				t.Errorf("%s: got %v, want only the first message", name, got)
+			}
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestPublishContextCancelled(t *testing.T) {
+	broker := NewBroker()
+	defer broker.Close()
+	full := broker.Subscribe("chat", 1, Block)
+	if err := broker.Publish(Message{Topic: "chat", Text: "fills the buffer"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := broker.PublishContext(ctx, Message{Topic: "chat"}); err != context.DeadlineExceeded {
+		t.Errorf("PublishContext to a full subscriber = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if msg := <-full.C; msg.Text != "fills the buffer" {
+		t.Errorf("got %v, want the first message", msg)
+	}
+}
+
+// TestTalkToBrokerStops checks that stopTalking returns even when the talker
+// is stuck publishing to a Block subscriber nobody reads.
+func TestTalkToBrokerStops(t *testing.T) {
+	err := Watch("talkToBroker", 5*time.Second, func() {
+		broker := NewBroker()
+		defer broker.Close()
+		stuck := broker.Subscribe("chat", 1, Block)
+		quit := make(chan string)
+		go talkToBroker("emily", "bar", "chat", broker, quit)
+		<-stuck.C
+		// The buffer refills, and the next Publish waits for a reader.
+		time.Sleep(200 * time.Millisecond)
+		if reply := stopTalking(quit); reply != "See you!" {
+			t.Errorf("stopTalking = %q, want See you!", reply)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the broker is gone, the talker still says goodbye.
+	err = Watch("talkToBroker after Close", 5*time.Second, func() {
+		broker := NewBroker()
+		quit := make(chan string)
+		go talkToBroker("john", "foo", "chat", broker, quit)
+		broker.Close()
+		stopTalking(quit)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "time"
+
+// Clock is the part of the time package that this package needs: SafeCache
+// expiry, and the timeouts of Conversation and SearchEngine. Each of them
+// takes a Clock, so tests can swap in a fake one instead of waiting on real
+// time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// orRealClock returns clock, or real time if clock is nil.
+func orRealClock(clock Clock) Clock {
+	if clock == nil {
+		return realClock{}
+	}
+	return clock
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to, with Advance.
+type fakeClock struct {
+	mux    sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+	// afters counts calls to After, see waitForAfters.
+	afters  int
+	changed *sync.Cond
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	clock := &fakeClock{now: time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)}
+	clock.changed = sync.NewCond(&clock.mux)
+	return clock
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// After returns a channel that gets the time once Advance reaches d from now.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	// Buffered, so Advance never waits for a timer nobody reads any more.
+	timer := fakeTimer{c.now.Add(d), make(chan time.Time, 1)}
+	if d <= 0 {
+		timer.c <- c.now
+	} else {
+		c.timers = append(c.timers, timer)
+	}
+	c.afters++
+	c.changed.Broadcast()
+	return timer.c
+}
+
+// Advance moves the clock forward by d, and fires every timer that is due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.c <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+// waitForAfters blocks until After has been called n times in all. Tests use
+// it to know the code under test is waiting, before they Advance.
+func (c *fakeClock) waitForAfters(n int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for c.afters < n {
+		c.changed.Wait()
+	}
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"./tree"
+	"context"
 	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
 	"sync"
-	"time"
 )
 
 func sum(s []int, c chan int) {
@@ -115,6 +118,11 @@ type SafeCounter struct {
 func (counter *SafeCounter) Inc(key string) {
 	// Lock so only one goroutine at a time can access the map c.v.
 	counter.mux.Lock()
+	// Writing to a nil map panics, so make it on first use. Then the zero
+	// value, &SafeCounter{}, is ready to use.
+	if counter.m == nil {
+		counter.m = make(map[string]int)
+	}
 	counter.m[key]++
 	counter.mux.Unlock()
 }
@@ -129,29 +137,192 @@ func (counter *SafeCounter) Value(key string) int {
 	return counter.m[key]
 }
 
+// crawler holds what every crawl goroutine shares.
+type crawler struct {
+	fetcher Fetcher
+	// cache may be a LinkCache left by an earlier crawl, then its pages are
+	// not fetched again.
+	cache Cache
+	// scope may be nil, then every link is followed.
+	scope *CrawlScope
+	// graph may be nil, then links are not recorded.
+	graph *LinkGraph
+	// outcomes may be nil, else it counts what happened to each url: "fetched",
+	// "error", "cache_hit", "resumed" or "filtered".
+	outcomes Counter
+
+	// followed are the pages whose links were followed during this crawl,
+	// so cached pages are only resumed once and cycles between them end.
+	followed map[string]bool
+	mux      sync.Mutex
+	// wg counts the crawl goroutines still running.
+	wg sync.WaitGroup
+}
+
 // Crawl uses fetcher to recursively crawl
 // pages starting with url, to a maximum of depth.
-func crawl(url string, depth int, fetcher Fetcher, safeCache *SafeCache) {
+// It returns once every page has been crawled.
+func crawl(url string, depth int, fetcher Fetcher, cache Cache) {
+	c := &crawler{fetcher: fetcher, cache: cache}
+	c.run(url, depth)
+}
+
+// run crawls from the seed url, and waits for all the goroutines it spawns.
+func (c *crawler) run(url string, depth int) {
+	c.crawl("", url, depth)
+	c.wg.Wait()
+}
+
+// crawl fetches url, which was linked from the page from ("" for the seed),
+// and recursively crawls its links.
+func (c *crawler) crawl(from, url string, depth int) {
 	if depth <= 0 {
 		return
 	}
+	// Different spellings of the same page share one cache entry.
+	url = normalizeURL(url)
 	// Don't fetch same URL twice!
-	if cachedBody, ok := safeCache.Get(url); ok {
-		fmt.Printf("Cache Hit: %s %q\n", url, cachedBody)
+	if cachedBody, ok := c.cache.Get(url); ok {
+		urls, ok := c.resume(url)
+		if !ok {
+			fmt.Printf("Cache Hit: %s %q\n", url, cachedBody)
+			c.count("cache_hit")
+			return
+		}
+		fmt.Printf("Resumed: %s %q\n", url, cachedBody)
+		c.count("resumed")
+		if c.graph != nil {
+			c.graph.AddPage(url, nil)
+		}
+		c.follow(url, urls, depth)
+		return
+	}
+	if !c.scope.takePage(from, url) {
+		c.count("filtered")
 		return
 	}
-	body, urls, err := fetcher.Fetch(url)
+	body, urls, err := c.fetcher.Fetch(url)
+	if c.graph != nil {
+		c.graph.AddPage(url, err)
+	}
 	if err != nil {
 		fmt.Println(err)
+		c.count("error")
 		return
 	}
 	fmt.Printf("200 OK: %s %q\n", url, body)
-	safeCache.Add(url, body)
+	c.count("fetched")
+	if linkCache, ok := c.cache.(LinkCache); ok {
+		linkCache.AddPage(url, body, urls)
+		c.markFollowed(url)
+	} else {
+		c.cache.Add(url, body)
+	}
+	c.follow(url, urls, depth)
+}
+
+func (c *crawler) count(outcome string) {
+	if c.outcomes != nil {
+		c.outcomes.Inc(outcome)
+	}
+}
+
+// markFollowed records that the links of url are being followed, and reports
+// whether that is the first time during this crawl.
+func (c *crawler) markFollowed(url string) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.followed == nil {
+		c.followed = make(map[string]bool)
+	}
+	first := !c.followed[url]
+	c.followed[url] = true
+	return first
+}
+
+// resume returns the links of url stored by an earlier crawl. It reports
+// false if the cache has no links for url, or they were followed already.
+func (c *crawler) resume(url string) ([]string, bool) {
+	linkCache, ok := c.cache.(LinkCache)
+	if !ok || !c.markFollowed(url) {
+		return nil, false
+	}
+	return linkCache.Links(url)
+}
+
+// follow crawls the links found on the page url.
+func (c *crawler) follow(url string, urls []string, depth int) {
 	for _, u := range urls {
-		// Spawn goroutine to recursively fetch url.
-		go crawl(u, depth-1, fetcher, safeCache)
+		u = normalizeURL(u)
+		if c.graph != nil {
+			c.graph.AddEdge(url, u, depth)
+		}
+		// Filtered links are reported by the scope, not dropped silently.
+		if !c.scope.Allow(url, u) {
+			c.count("filtered")
+			continue
+		}
+		// Spawn goroutine to recursively fetch url. Add must happen before
+		// the goroutine starts, or run might Wait before it is counted.
+		c.wg.Add(1)
+		go func(u string) {
+			defer c.wg.Done()
+			c.crawl(url, u, depth-1)
+		}(u)
+	}
+}
+
+// resumeCrawl stops a crawl over a DiskCache after two pages, as if the
+// process died, then starts it again from the same log.
+func resumeCrawl() {
+	dir, err := os.MkdirTemp("", "crawl")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "cache.log")
+
+	diskCache, err := OpenDiskCache(logPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	interrupted := crawler{
+		fetcher: FakeFetcherImpl,
+		cache:   diskCache,
+		scope:   &CrawlScope{MaxPages: 2},
+	}
+	interrupted.run("https://golang.org/", 4)
+	diskCache.Close()
+
+	// Only the pages missing from the log are fetched this time.
+	diskCache, err = OpenDiskCache(logPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer diskCache.Close()
+	crawl("https://golang.org/", 4, FakeFetcherImpl, diskCache)
+}
+
+// countConcurrently increments a SafeCounter from n goroutines and returns
+// the total. A WaitGroup is how to wait for all goroutines to complete:
+// https://gobyexample.com/waitgroups
+// Sleeping "long enough" instead is slow, and flaky on a busy machine.
+func countConcurrently(n int) int {
+	safeCounter := SafeCounter{m: make(map[string]int)}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		// Add before starting the goroutine, so Wait can't miss it.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			safeCounter.Inc("somekey")
+		}()
 	}
-	return
+	wg.Wait()
+	return safeCounter.Value("somekey")
 }
 
 // ConcurrencyMain entry point for concurrency.
@@ -172,6 +343,11 @@ func ConcurrencyMain() {
 	x, y := <-c, <-c // receive from c
 	fmt.Println("First routine:", x, "Second routine:", y, "sum:", x+y)
 
+	// The same split, generalized: 3 pieces on a pool of 2 goroutines. add is
+	// from basics.go, and works both to fold in an item and to merge pieces.
+	total, err := ParallelReduce(context.Background(), s, 3, 2, 0, add, add)
+	fmt.Println("ParallelReduce sum:", total, err)
+
 	// You can close a channel with `close(c)`` and test if a channel is closed on read
 	// `val, ok := <-ch`. You can also use `for i := range c {...}` to loop until close.
 	// Note: Only the SENDER should close a channel. Sending on closed channel causes panic.
@@ -183,6 +359,16 @@ func ConcurrencyMain() {
 		fmt.Println(gened)
 	}
 
+	// The same pattern as composable stages, see pipeline.go. Squares of the
+	// odd numbers in 0..9, two at a time. Cancelling stops all the stages.
+	ctx, cancel := context.WithCancel(context.Background())
+	odds := Filter(ctx, Generate(ctx, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9), func(n int) bool { return n%2 == 1 })
+	squares := Map(ctx, odds, func(n int) int { return n * n })
+	for batch := range Batch(ctx, Take(ctx, squares, 4), 2) {
+		fmt.Println(batch)
+	}
+	cancel()
+
 	dataChan := make(chan int)
 	quitChan := make(chan int)
 	go func() {
@@ -195,6 +381,15 @@ func ConcurrencyMain() {
 	}()
 	channelSelect(dataChan, quitChan)
 
+	// The same generator, with cancel instead of quitChan and no limit on
+	// size. Take 100 values, and print the last: way past what an int holds.
+	ctx, cancel = context.WithCancel(context.Background())
+	var fib *big.Int
+	for fib = range Take(ctx, Fibonacci(ctx), 100) {
+	}
+	cancel()
+	fmt.Println("100th Fibonacci number:", fib)
+
 	// true
 	fmt.Println(SameInOrderTraversal(tree.New(1), tree.New(1)))
 	// false
@@ -208,17 +403,53 @@ func ConcurrencyMain() {
 	fmt.Println(SameInOrderTraversalGeneric(tree.New(100), tree.New(200)))
 
 	// Mutexes.
-	safeCounter := SafeCounter{m: make(map[string]int)}
-	for i := 0; i < 100; i++ {
-		go safeCounter.Inc("somekey")
-	}
-	// Delay to give enough time for the goroutines to finish.
-	time.Sleep(time.Second)
-	fmt.Println(safeCounter.Value("somekey")) // 100
+	fmt.Println(countConcurrently(100)) // 100
 
-	// Note, the time.Sleep is a poor way to wait for all goroutines to complete.
-	// Use WaitGroups instead for real impl: https://gobyexample.com/waitgroups
-	safeCache := SafeCache{cache: make(map[string]string)}
+	// crawl waits for its goroutines with a WaitGroup too, so there is no
+	// need to sleep after it.
+	safeCache := SafeCache{}
 	crawl("https://golang.org/", 4, FakeFetcherImpl, &safeCache)
-	time.Sleep(time.Second)
+	fmt.Printf("Cache stats: %+v\n", safeCache.Stats())
+
+	// Same crawl, but only under /pkg/ and at most 3 pages.
+	scopedCrawler := crawler{
+		fetcher: FakeFetcherImpl,
+		cache:   &SafeCache{},
+		scope: &CrawlScope{
+			Rules:    []ScopeRule{SameHost(), PathPrefixes("/pkg/")},
+			MaxPages: 3,
+		},
+		// Any Counter works here, e.g. &SafeCounter{} too.
+		outcomes: &AtomicCounter{},
+	}
+	scopedCrawler.run("https://golang.org/pkg/", 4)
+	scopedCrawler.outcomes.WriteText(os.Stdout)
+	fmt.Println("Most common:", scopedCrawler.outcomes.TopK(1))
+
+	// Record the link graph of a full crawl, and print it.
+	graphCrawler := crawler{
+		fetcher: FakeFetcherImpl,
+		cache:   &SafeCache{},
+		graph:   NewLinkGraph(),
+	}
+	graphCrawler.run("https://golang.org/", 4)
+	graphCrawler.graph.WriteAdjacency(os.Stdout)
+	stats := graphCrawler.graph.Stats("https://golang.org/")
+	fmt.Println("In degree:", stats.InDegree)
+	fmt.Println("Broken links:", stats.BrokenLinks)
+	fmt.Println("Not fetched:", stats.Unfetched)
+
+	// Fetchers can also be loaded from fixture files, see fixtures/.
+	fixtureFetcher, err := LoadFakeFetcher("fixtures/broken.json")
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		// Prints the link to https://example.com/missing/.
+		fmt.Println(fixtureFetcher.Validate())
+		crawl("https://example.com/", 3, fixtureFetcher, &SafeCache{})
+	}
+
+	// A real site can be recorded once and replayed without the network, see
+	// replay_fetcher_test.go.
+	resumeCrawl()
 }
@@ -1,28 +1,337 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 )
 
-func talkToGeneratedChannel(gopher, msg string) chan string {
+// talk sends numbered messages to c, with a random pause between them, until
+// told to stop on quit. Then it runs cleanup (if not nil) and confirms on the
+// same quit channel, so the other side knows the goroutine is really done.
+// This is the "round trip" quit from the concurrency talk.
+func talk(gopher, msg string, c chan<- string, quit chan string, cleanup func()) {
+	stop := func() {
+		if cleanup != nil {
+			cleanup()
+		}
+		quit <- "See you!"
+	}
+	for i := 0; ; i++ {
+		// Every blocking step also waits on quit, otherwise a gopher stuck
+		// on a send nobody reads would never hear it.
+		select {
+		case c <- fmt.Sprintf("Message %d from %s says: %s\n", i, gopher, msg):
+		case <-quit:
+			stop()
+			return
+		}
+		select {
+		case <-time.After(time.Duration(rand.Intn(1e3)) * time.Millisecond):
+		case <-quit:
+			stop()
+			return
+		}
+	}
+}
+
+func talkToGeneratedChannel(gopher, msg string, quit chan string) chan string {
 	c := make(chan string)
 	// This creates a closure, because it references c variable outside of it.
+	// The talker owns c, so it closes it on the way out.
+	go talk(gopher, msg, c, quit, func() { close(c) })
+	return c
+}
+
+func talkToChannel(gopher, msg string, c chan string, quit chan string) {
+	// c is shared with other gophers, so it is not closed here.
+	talk(gopher, msg, c, quit, nil)
+}
+
+// stopTalking tells a talker to quit and waits until it confirms.
+func stopTalking(quit chan string) string {
+	quit <- "Bye!"
+	return <-quit
+}
+
+// TimeoutEvent reports a wait that took too long.
+type TimeoutEvent struct {
+	// Kind is "message" when one message took longer than MessageTimeout,
+	// or "conversation" when the whole Deadline passed.
+	Kind string
+	// Received is how many messages had arrived by then.
+	Received int
+	// Waited is how long since the conversation started.
+	Waited time.Duration
+}
+
+func (e TimeoutEvent) String() string {
+	return fmt.Sprintf("%s timeout after %v, %d messages received", e.Kind, e.Waited, e.Received)
+}
+
+// Conversation listens to a channel of messages with time limits.
+type Conversation struct {
+	// MessageTimeout is how long to wait for each message. 0 means forever.
+	MessageTimeout time.Duration
+	// Deadline is how long the whole conversation may take. 0 means forever.
+	Deadline time.Duration
+	// Clock is nil for real time.
+	Clock Clock
+}
+
+// Listen reads up to n messages from c. A message that is late is reported
+// as a "message" event and waited for again; when the deadline passes (or c
+// is closed) Listen stops and returns what it got. It never hangs past the
+// deadline.
+func (conv Conversation) Listen(c <-chan string, n int) (msgs []string, events []TimeoutEvent) {
+	clock := orRealClock(conv.Clock)
+	start := clock.Now()
+	// A nil channel never fires, so without a limit its case never wins.
+	var deadline <-chan time.Time
+	if conv.Deadline > 0 {
+		deadline = clock.After(conv.Deadline)
+	}
+	for len(msgs) < n {
+		var late <-chan time.Time
+		if conv.MessageTimeout > 0 {
+			late = clock.After(conv.MessageTimeout)
+		}
+		select {
+		case msg, ok := <-c:
+			if !ok {
+				return msgs, events
+			}
+			msgs = append(msgs, msg)
+		case <-late:
+			events = append(events, TimeoutEvent{"message", len(msgs), clock.Now().Sub(start)})
+		case <-deadline:
+			events = append(events, TimeoutEvent{"conversation", len(msgs), clock.Now().Sub(start)})
+			return msgs, events
+		}
+	}
+	return msgs, events
+}
+
+// Multiplexer fans in any number of source channels into one output
+// channel. Sources can join with Add and leave with Remove while it runs, and
+// a source also leaves when it is closed. Once the last source has left, the
+// output is closed, so the reader can range over it.
+//
+// A Multiplexer that never gets a source has no last source to leave, so its
+// output stays open until Close.
+type Multiplexer[T any] struct {
+	out chan T
+	mux sync.Mutex
+	// sources maps a source id to its forward goroutine.
+	sources map[int]forwarder
+	nextID  int
+	// active counts the forward goroutines still running.
+	active int
+	// closing is set by Close, and closed once out is closed. Either way Add
+	// is refused.
+	closing, closed bool
+}
+
+// forwarder is how Remove and Close talk to a forward goroutine: closing
+// quit tells it to leave, and it closes done once it has.
+type forwarder struct {
+	quit, done chan struct{}
+}
+
+// NewMultiplexer returns a Multiplexer with no sources yet.
+func NewMultiplexer[T any]() *Multiplexer[T] {
+	return &Multiplexer[T]{out: make(chan T), sources: make(map[int]forwarder)}
+}
+
+// Out is the merged channel.
+func (m *Multiplexer[T]) Out() <-chan T {
+	return m.out
+}
+
+// Add starts forwarding source to the output, and returns the id to Remove
+// it with. It reports false if the output is already closed, or Close was
+// called.
+func (m *Multiplexer[T]) Add(source <-chan T) (int, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closing || m.closed {
+		return 0, false
+	}
+	id := m.nextID
+	m.nextID++
+	f := forwarder{quit: make(chan struct{}), done: make(chan struct{})}
+	m.sources[id] = f
+	m.active++
+	go m.forward(id, source, f)
+	return id, true
+}
+
+// forward copies source to the output until source is closed or removed.
+func (m *Multiplexer[T]) forward(id int, source <-chan T, f forwarder) {
+	defer close(f.done)
+	defer m.leave(id)
+	for {
+		// select picks at random when both are ready, so look at quit first:
+		// once removed, nothing more is read from source.
+		select {
+		case <-f.quit:
+			return
+		default:
+		}
+		select {
+		case v, ok := <-source:
+			if !ok {
+				return
+			}
+			select {
+			case m.out <- v:
+			case <-f.quit:
+				// v was read before the Remove. Hand it over if a reader is
+				// waiting; it is only lost if nobody is.
+				select {
+				case m.out <- v:
+				default:
+				}
+				return
+			}
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// Remove stops forwarding the source with the given id, and returns once
+// nothing more will be read from it. It does not close the source, which
+// belongs to whoever writes to it.
+func (m *Multiplexer[T]) Remove(id int) {
+	m.mux.Lock()
+	f, ok := m.sources[id]
+	if ok {
+		close(f.quit)
+		// Delete now, so a second Remove doesn't close quit again. forward
+		// calls leave when it notices.
+		delete(m.sources, id)
+	}
+	// Unlock before waiting: forward takes mux in leave on its way out.
+	m.mux.Unlock()
+	if ok {
+		<-f.done
+	}
+}
+
+// Close removes every source and refuses new ones, and returns once they
+// have all left. The output is closed then, or right away if there were no
+// sources.
+func (m *Multiplexer[T]) Close() {
+	m.mux.Lock()
+	m.closing = true
+	var removed []forwarder
+	for id, f := range m.sources {
+		close(f.quit)
+		delete(m.sources, id)
+		removed = append(removed, f)
+	}
+	m.closeIfIdle()
+	m.mux.Unlock()
+	for _, f := range removed {
+		<-f.done
+	}
+}
+
+// leave is called by forward once its source is gone. The last one to
+// leave closes the output.
+func (m *Multiplexer[T]) leave(id int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.sources, id)
+	m.active--
+	m.closeIfIdle()
+}
+
+// closeIfIdle closes the output if no source is forwarding. Callers hold mux.
+func (m *Multiplexer[T]) closeIfIdle() {
+	if m.active == 0 && !m.closed {
+		m.closed = true
+		close(m.out)
+	}
+}
+
+// DaisyChain links n goroutines in a row, the "Chinese whispers" example
+// from the talk. Each link reads a value from its left, applies step, and
+// passes the result to its right. Values sent to in come out of out having
+// gone through step n times, in order.
+//
+// Close in to shut the chain down: each link closes its right channel once
+// its left one is closed, so out is closed only after every link has exited.
+// A chain of n < 1 is a single link that passes values on unchanged.
+func DaisyChain(n int, step func(int) int) (in chan<- int, out <-chan int) {
+	if n < 1 {
+		// in and out must be different channels, or a send on in would wait
+		// for a reader that can only come after it.
+		n, step = 1, func(v int) int { return v }
+	}
+	leftmost := make(chan int)
+	left := leftmost
+	for i := 0; i < n; i++ {
+		right := make(chan int)
+		go func(left <-chan int, right chan<- int) {
+			defer close(right)
+			for v := range left {
+				right <- step(v)
+			}
+		}(left, right)
+		left = right
+	}
+	return leftmost, left
+}
+
+// countFrom emits n, n+1, n+2, ... until ctx is cancelled.
+func countFrom(ctx context.Context, n int) <-chan int {
+	out := make(chan int)
 	go func() {
-		for i := 0; ; i++ {
-			c <- fmt.Sprintf("Message %d from %s says: %s\n", i, gopher, msg)
-			time.Sleep(time.Duration(rand.Intn(1e3)) * time.Millisecond)
+		defer close(out)
+		for {
+			if !send(ctx, out, n) {
+				return
+			}
+			n++
 		}
 	}()
-	return c
+	return out
 }
 
-func talkToChannel(gopher, msg string, c chan string) {
-	for i := 0; ; i++ {
-		c <- fmt.Sprintf("Message %d from %s says: %s\n", i, gopher, msg)
-		time.Sleep(time.Duration(rand.Intn(1e3)) * time.Millisecond)
-	}
+// PrimeSieve emits the first count primes, using the concurrent sieve from
+// the Go docs: every prime found adds a Filter stage (see pipeline.go) that
+// drops its multiples. It cancels all of its stages after the last prime,
+// and closes the output only once every one of them has exited.
+func PrimeSieve(ctx context.Context, count int) <-chan int {
+	out := make(chan int)
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer close(out)
+		ch := countFrom(ctx, 2)
+		stages := []<-chan int{ch}
+		defer func() {
+			// Every stage returns as soon as it sees the cancel, and closes
+			// its output on the way out.
+			cancel()
+			for _, stage := range stages {
+				for range stage {
+				}
+			}
+		}()
+		for i := 0; i < count; i++ {
+			prime, ok := recv(ctx, ch)
+			if !ok || !send(ctx, out, prime) {
+				return
+			}
+			ch = Filter(ctx, ch, func(n int) bool { return n%prime != 0 })
+			stages = append(stages, ch)
+		}
+	}()
+	return out
 }
 
 // TalkingGophers is an example from the slides on concurrency.
@@ -30,21 +339,79 @@ func talkToChannel(gopher, msg string, c chan string) {
 func TalkingGophers() {
 	// talk to shared channel.
 	c := make(chan string)
-	go talkToChannel("emily", "bar", c)
-	go talkToChannel("john", "foo", c)
+	emilyQuit, johnQuit := make(chan string), make(chan string)
+	go talkToChannel("emily", "bar", c, emilyQuit)
+	go talkToChannel("john", "foo", c, johnQuit)
 	for i := 0; i < 5; i++ {
 		fmt.Print(<-c)
 	}
+	// Without this, both goroutines would be stuck on `c <-` for the rest of
+	// the program.
+	fmt.Println("emily says:", stopTalking(emilyQuit))
+	fmt.Println("john says:", stopTalking(johnQuit))
 
 	// talk in lock step
 	var sam, steve chan string
-	sam = talkToGeneratedChannel("sam", "one")
-	steve = talkToGeneratedChannel("steve", "two")
+	samQuit, steveQuit := make(chan string), make(chan string)
+	sam = talkToGeneratedChannel("sam", "one", samQuit)
+	steve = talkToGeneratedChannel("steve", "two", steveQuit)
 	for i := 0; i < 5; i++ {
 		fmt.Print(<-sam)
 		fmt.Print(<-steve)
 	}
+	stopTalking(samQuit)
+	stopTalking(steveQuit)
 
-	// Fan-in approach
+	// Fan-in approach. Whoever has something to say goes first, so a slow
+	// gopher no longer holds up a fast one, like in lock step.
+	fanIn := NewMultiplexer[string]()
+	sarahQuit := make(chan string)
+	fanIn.Add(talkToGeneratedChannel("sam", "one", samQuit))
+	steveID, _ := fanIn.Add(talkToGeneratedChannel("steve", "two", steveQuit))
+	for i := 0; i < 10; i++ {
+		fmt.Print(<-fanIn.Out())
+	}
+	// steve leaves, and a newcomer joins.
+	fanIn.Remove(steveID)
+	stopTalking(steveQuit)
+	fanIn.Add(talkToGeneratedChannel("sarah", "three", sarahQuit))
+	for i := 0; i < 5; i++ {
+		fmt.Print(<-fanIn.Out())
+	}
+	// A stopped talker closes its channel, which makes it leave the
+	// Multiplexer. Once everybody left, Out is closed and the range ends.
+	stopTalking(samQuit)
+	stopTalking(sarahQuit)
+	for msg := range fanIn.Out() {
+		fmt.Print(msg)
+	}
 
+	// Timeouts. Gophers pause up to 1s, so some messages are late, and 10 of
+	// them likely don't fit in 2.5s.
+	c = make(chan string)
+	go talkToChannel("emily", "bar", c, emilyQuit)
+	conv := Conversation{MessageTimeout: 700 * time.Millisecond, Deadline: 2500 * time.Millisecond}
+	msgs, events := conv.Listen(c, 10)
+	for _, msg := range msgs {
+		fmt.Print(msg)
+	}
+	for _, event := range events {
+		fmt.Println(event)
+	}
+	stopTalking(emilyQuit)
+
+	// Daisy chain. 100000 goroutines, each adding 1.
+	in, out := DaisyChain(100000, func(v int) int { return v + 1 })
+	in <- 1
+	fmt.Println("Daisy chain:", <-out)
+	close(in)
+	// Drains once every link has exited.
+	for range out {
+	}
+
+	// Prime sieve.
+	for prime := range PrimeSieve(context.Background(), 10) {
+		fmt.Print(prime, " ")
+	}
+	fmt.Println()
 }
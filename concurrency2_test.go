@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// source sends from, from+1, ..., to-1 on a new channel, then closes it.
+func source(from, to int) <-chan int {
+	c := make(chan int)
+	go func() {
+		defer close(c)
+		for i := from; i < to; i++ {
+			c <- i
+		}
+	}()
+	return c
+}
+
+func TestMultiplexerMergesInOrder(t *testing.T) {
+	err := Watch("Multiplexer", 5*time.Second, func() {
+		m := NewMultiplexer[int]()
+		m.Add(source(0, 100))
+		m.Add(source(1000, 1100))
+		m.Add(source(2000, 2100))
+		// Each source keeps its order, and Out closes once all three did.
+		last := map[int]int{0: -1, 1: 999, 2: 1999}
+		got := 0
+		for v := range m.Out() {
+			if v <= last[v/1000] {
+				t.Errorf("%d came after %d", v, last[v/1000])
+			}
+			last[v/1000] = v
+			got++
+		}
+		if got != 300 {
+			t.Errorf("got %d values, want 300", got)
+		}
+		if _, ok := m.Add(make(chan int)); ok {
+			t.Error("Add after the output closed succeeded")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultiplexerAddRemoveWhileRunning(t *testing.T) {
+	err := Watch("Multiplexer", 5*time.Second, func() {
+		m := NewMultiplexer[int]()
+		// a never closes, so only Remove makes it leave.
+		a := make(chan int)
+		aID, _ := m.Add(a)
+		a <- 1
+		if v := <-m.Out(); v != 1 {
+			t.Errorf("got %d from a, want 1", v)
+		}
+
+		// b joins while a is still there.
+		b := make(chan int)
+		m.Add(b)
+		go func() { b <- 2 }()
+		if v := <-m.Out(); v != 2 {
+			t.Errorf("got %d from b, want 2", v)
+		}
+
+		// Once a is removed, nothing it sends gets through.
+		m.Remove(aID)
+		m.Remove(aID)
+		select {
+		case a <- 3:
+			t.Error("a removed, but its value was still read")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// b leaves by closing, the last source, so Out closes.
+		close(b)
+		if v, ok := <-m.Out(); ok {
+			t.Errorf("got %d after every source left, want Out closed", v)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultiplexerClose(t *testing.T) {
+	err := Watch("Multiplexer", 5*time.Second, func() {
+		// Without a source, only Close closes Out.
+		empty := NewMultiplexer[int]()
+		empty.Close()
+		if _, ok := <-empty.Out(); ok {
+			t.Error("Out of an empty, closed Multiplexer is still open")
+		}
+
+		// With sources, Close removes them.
+		m := NewMultiplexer[int]()
+		never := make(chan int)
+		m.Add(never)
+		m.Add(never)
+		m.Close()
+		m.Close()
+		if _, ok := <-m.Out(); ok {
+			t.Error("Out still open after Close")
+		}
+		if _, ok := m.Add(never); ok {
+			t.Error("Add after Close succeeded")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTalkersStop checks the quit handshake: once stopTalking returns, the
+// talker's goroutine is gone, so the goroutines are back to where they
+// started.
+func TestTalkersStop(t *testing.T) {
+	err := Watch("talkToGeneratedChannel", 5*time.Second, func() {
+		quit := make(chan string)
+		c := talkToGeneratedChannel("sam", "one", quit)
+		<-c
+		if reply := stopTalking(quit); reply != "See you!" {
+			t.Errorf("stopTalking = %q, want See you!", reply)
+		}
+		// The talker closed its channel on the way out.
+		if _, ok := <-c; ok {
+			t.Error("channel still open after stopTalking")
+		}
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = Watch("talkToChannel", 5*time.Second, func() {
+		c := make(chan string)
+		emilyQuit, johnQuit := make(chan string), make(chan string)
+		go talkToChannel("emily", "bar", c, emilyQuit)
+		go talkToChannel("john", "foo", c, johnQuit)
+		<-c
+		// Nobody reads c any more, so both are stuck on a send, or pausing.
+		stopTalking(emilyQuit)
+		stopTalking(johnQuit)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConversationListen(t *testing.T) {
+	// Everything arrives in time: no events.
+	c := make(chan string, 3)
+	c <- "a"
+	c <- "b"
+	c <- "c"
+	conv := Conversation{MessageTimeout: time.Second, Deadline: time.Minute, Clock: newFakeClock()}
+	msgs, events := conv.Listen(c, 2)
+	if !reflect.DeepEqual(msgs, []string{"a", "b"}) || len(events) != 0 {
+		t.Errorf("Listen = %v, %v, want a and b with no events", msgs, events)
+	}
+
+	// A closed channel ends the conversation early.
+	close(c)
+	msgs, events = conv.Listen(c, 5)
+	if !reflect.DeepEqual(msgs, []string{"c"}) || len(events) != 0 {
+		t.Errorf("Listen of a closed channel = %v, %v, want c with no events", msgs, events)
+	}
+}
+
+func TestConversationTimeouts(t *testing.T) {
+	clock := newFakeClock()
+	conv := Conversation{MessageTimeout: 5 * time.Second, Deadline: 7 * time.Second, Clock: clock}
+	c := make(chan string)
+	type result struct {
+		msgs   []string
+		events []TimeoutEvent
+	}
+	done := make(chan result)
+	go func() {
+		msgs, events := conv.Listen(c, 10)
+		done <- result{msgs, events}
+	}()
+
+	// After 1 is the deadline, After 2 the first message timeout.
+	clock.waitForAfters(2)
+	clock.Advance(5 * time.Second)
+	// The late message is reported, and waited for again.
+	clock.waitForAfters(3)
+	c <- "hello"
+	clock.waitForAfters(4)
+	// The deadline passes before the next message timeout.
+	clock.Advance(2 * time.Second)
+
+	select {
+	case got := <-done:
+		if !reflect.DeepEqual(got.msgs, []string{"hello"}) {
+			t.Errorf("msgs = %v, want hello", got.msgs)
+		}
+		want := []TimeoutEvent{
+			{Kind: "message", Received: 0, Waited: 5 * time.Second},
+			{Kind: "conversation", Received: 1, Waited: 7 * time.Second},
+		}
+		if !reflect.DeepEqual(got.events, want) {
+			t.Errorf("events = %v, want %v", got.events, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen is still waiting after the deadline")
+	}
+}
+
+func TestDaisyChain(t *testing.T) {
+	for _, n := range []int{-1, 0, 1, 2, 1000} {
+		err := Watch("DaisyChain", 5*time.Second, func() {
+			in, out := DaisyChain(n, func(v int) int { return v + 1 })
+			go func() {
+				defer close(in)
+				for v := 0; v < 10; v++ {
+					in <- 10 * v
+				}
+			}()
+			// Every value went through each link once, in order, and out
+			// closes once in did.
+			links := n
+			if links < 0 {
+				links = 0
+			}
+			want := make([]int, 10)
+			for v := range want {
+				want[v] = 10*v + links
+			}
+			if got := collect(out); !reflect.DeepEqual(got, want) {
+				t.Errorf("DaisyChain(%d) = %v, want %v", n, got, want)
+			}
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestPrimeSieve(t *testing.T) {
+	tests := []struct {
+		count int
+		want  []int
+	}{
+		{-1, nil},
+		{0, nil},
+		{1, []int{2}},
+		{10, []int{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}},
+	}
+	for _, test := range tests {
+		err := Watch("PrimeSieve", 5*time.Second, func() {
+			if got := collect(PrimeSieve(context.Background(), test.count)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("PrimeSieve(%d) = %v, want %v", test.count, got, test.want)
+			}
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Cancelling part way closes the output, and every stage exits.
+	err := Watch("PrimeSieve cancelled", 5*time.Second, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		primes := PrimeSieve(ctx, 1000)
+		for i := 0; i < 20; i++ {
+			<-primes
+		}
+		cancel()
+		for range primes {
+		}
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkDaisyChain(b *testing.B) {
+	for _, n := range []int{1, 100, 10000} {
+		b.Run(fmt.Sprintf("links=%d", n), func(b *testing.B) {
+			in, out := DaisyChain(n, func(v int) int { return v + 1 })
+			defer func() {
+				close(in)
+				for range out {
+				}
+			}()
+			for i := 0; i < b.N; i++ {
+				in <- i
+				if v := <-out; v != i+n {
+					b.Fatalf("got %d, want %d", v, i+n)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPrimeSieve(b *testing.B) {
+	for _, count := range []int{10, 100, 500} {
+		b.Run(fmt.Sprintf("count=%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if got := len(collect(PrimeSieve(context.Background(), count))); got != count {
+					b.Fatalf("got %d primes, want %d", got, count)
+				}
+			}
+		})
+	}
+}
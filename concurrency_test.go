@@ -0,0 +1,11 @@
+package main
+
+import "testing"
+
+func TestCountConcurrently(t *testing.T) {
+	for _, n := range []int{0, 1, 100, 1000} {
+		if got := countConcurrently(n); got != n {
+			t.Errorf("countConcurrently(%d) = %d, want exactly %d", n, got, n)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ScopeRule decides whether the crawler may follow a link to target, found on
+// the page from. It returns the reason the link is out of scope, or "" to
+// allow it. Rules are plain functions, so they compose with AllOf and AnyOf.
+type ScopeRule func(from, target *url.URL) string
+
+// SameHost only allows links that stay on the host of the linking page.
+func SameHost() ScopeRule {
+	return func(from, target *url.URL) string {
+		if from != nil && target.Host != from.Host {
+			return fmt.Sprintf("host %s is not %s", target.Host, from.Host)
+		}
+		return ""
+	}
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains.
+func matchesDomain(host, domain string) bool {
+	host, domain = strings.ToLower(host), strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// AllowDomains only allows links to the given domains and their subdomains.
+func AllowDomains(domains ...string) ScopeRule {
+	return func(from, target *url.URL) string {
+		for _, domain := range domains {
+			if matchesDomain(target.Hostname(), domain) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("domain %s is not allowed", target.Hostname())
+	}
+}
+
+// DenyDomains rejects links to the given domains and their subdomains.
+func DenyDomains(domains ...string) ScopeRule {
+	return func(from, target *url.URL) string {
+		for _, domain := range domains {
+			if matchesDomain(target.Hostname(), domain) {
+				return fmt.Sprintf("domain %s is denied", target.Hostname())
+			}
+		}
+		return ""
+	}
+}
+
+// PathPrefixes only allows links whose path starts with one of the prefixes.
+func PathPrefixes(prefixes ...string) ScopeRule {
+	return func(from, target *url.URL) string {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(target.Path, prefix) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("path %s is outside %v", target.Path, prefixes)
+	}
+}
+
+// Include only allows links whose full URL matches re.
+func Include(re *regexp.Regexp) ScopeRule {
+	return func(from, target *url.URL) string {
+		if !re.MatchString(target.String()) {
+			return fmt.Sprintf("does not match %s", re)
+		}
+		return ""
+	}
+}
+
+// Exclude rejects links whose full URL matches re.
+func Exclude(re *regexp.Regexp) ScopeRule {
+	return func(from, target *url.URL) string {
+		if re.MatchString(target.String()) {
+			return fmt.Sprintf("matches excluded %s", re)
+		}
+		return ""
+	}
+}
+
+// AllOf allows a link only if every rule allows it. It reports the first rejection.
+func AllOf(rules ...ScopeRule) ScopeRule {
+	return func(from, target *url.URL) string {
+		for _, rule := range rules {
+			if reason := rule(from, target); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	}
+}
+
+// AnyOf allows a link if at least one rule allows it.
+func AnyOf(rules ...ScopeRule) ScopeRule {
+	return func(from, target *url.URL) string {
+		var reasons []string
+		for _, rule := range rules {
+			reason := rule(from, target)
+			if reason == "" {
+				return ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return strings.Join(reasons, " and ")
+	}
+}
+
+// FilteredURL is a link the crawler did not follow, and why.
+type FilteredURL struct {
+	From, URL, Reason string
+}
+
+// CrawlScope limits what the crawler follows. The zero value follows everything.
+// It is safe for concurrent use, since every crawl goroutine shares one.
+type CrawlScope struct {
+	// Rules must all allow a link before it is followed.
+	Rules []ScopeRule
+	// MaxPages caps the number of pages fetched. 0 means no limit.
+	MaxPages int
+
+	mux      sync.Mutex
+	pages    int
+	filtered []FilteredURL
+}
+
+// Allow reports whether the link from -> target is in scope. Rejected links
+// are recorded, see Filtered.
+func (scope *CrawlScope) Allow(from, target string) bool {
+	if scope == nil {
+		return true
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		scope.reject(from, target, err.Error())
+		return false
+	}
+	var fromURL *url.URL
+	if from != "" {
+		// The linking page was fetched already, so it parses.
+		fromURL, _ = url.Parse(from)
+	}
+	if reason := AllOf(scope.Rules...)(fromURL, targetURL); reason != "" {
+		scope.reject(from, target, reason)
+		return false
+	}
+	return true
+}
+
+// takePage claims one page from the MaxPages budget, and reports whether
+// there was any left. Called right before a fetch.
+func (scope *CrawlScope) takePage(from, target string) bool {
+	if scope == nil {
+		return true
+	}
+	scope.mux.Lock()
+	if scope.MaxPages > 0 && scope.pages >= scope.MaxPages {
+		scope.mux.Unlock()
+		scope.reject(from, target, fmt.Sprintf("page limit of %d reached", scope.MaxPages))
+		return false
+	}
+	scope.pages++
+	scope.mux.Unlock()
+	return true
+}
+
+func (scope *CrawlScope) reject(from, target, reason string) {
+	fmt.Printf("Filtered: %s (%s)\n", target, reason)
+	scope.mux.Lock()
+	defer scope.mux.Unlock()
+	scope.filtered = append(scope.filtered, FilteredURL{from, target, reason})
+}
+
+// Filtered returns a copy of every link that was not followed.
+func (scope *CrawlScope) Filtered() []FilteredURL {
+	if scope == nil {
+		return nil
+	}
+	scope.mux.Lock()
+	defer scope.mux.Unlock()
+	return append([]FilteredURL(nil), scope.filtered...)
+}
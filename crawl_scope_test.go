@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestScopeRules(t *testing.T) {
+	tests := []struct {
+		name         string
+		rule         ScopeRule
+		from, target string
+		// want is the reason the link is rejected, "" if it is allowed.
+		want string
+	}{
+		{"same host", SameHost(), "https://golang.org/", "https://golang.org/pkg/", ""},
+		{"other host", SameHost(), "https://golang.org/", "https://go.dev/", "host go.dev is not golang.org"},
+		{"subdomain is another host", SameHost(), "https://golang.org/", "https://blog.golang.org/", "host blog.golang.org is not golang.org"},
+		{"other port is another host", SameHost(), "https://golang.org/", "https://golang.org:8080/", "host golang.org:8080 is not golang.org"},
+		{"seed has no host to stay on", SameHost(), "", "https://go.dev/", ""},
+
+		{"allowed domain", AllowDomains("golang.org"), "", "https://golang.org/", ""},
+		{"allowed subdomain", AllowDomains("golang.org"), "", "https://blog.golang.org/", ""},
+		{"allowed domain any case", AllowDomains("GoLang.org"), "", "https://GOLANG.ORG/", ""},
+		{"allowed domain with port", AllowDomains("golang.org"), "", "https://golang.org:8080/", ""},
+		{"one of many allowed", AllowDomains("go.dev", "golang.org"), "", "https://golang.org/", ""},
+		{"suffix is not a subdomain", AllowDomains("golang.org"), "", "https://notgolang.org/", "domain notgolang.org is not allowed"},
+		{"parent is not allowed", AllowDomains("blog.golang.org"), "", "https://golang.org/", "domain golang.org is not allowed"},
+		{"no domains allowed", AllowDomains(), "", "https://golang.org/", "domain golang.org is not allowed"},
+
+		{"denied domain", DenyDomains("go.dev"), "", "https://go.dev/", "domain go.dev is denied"},
+		{"denied subdomain", DenyDomains("go.dev"), "", "https://pkg.go.dev/", "domain pkg.go.dev is denied"},
+		{"suffix is not denied", DenyDomains("golang.org"), "", "https://notgolang.org/", ""},
+		{"other domain", DenyDomains("go.dev"), "", "https://golang.org/", ""},
+
+		{"path prefix", PathPrefixes("/pkg/"), "", "https://golang.org/pkg/fmt/", ""},
+		{"second path prefix", PathPrefixes("/cmd/", "/pkg/"), "", "https://golang.org/pkg/", ""},
+		{"outside path prefix", PathPrefixes("/pkg/"), "", "https://golang.org/cmd/", "path /cmd/ is outside [/pkg/]"},
+		{"prefix needs its slash", PathPrefixes("/pkg/"), "", "https://golang.org/pkg", "path /pkg is outside [/pkg/]"},
+
+		{"include match", Include(regexp.MustCompile(`/pkg/`)), "", "https://golang.org/pkg/os/", ""},
+		{"include no match", Include(regexp.MustCompile(`/pkg/`)), "", "https://golang.org/", "does not match /pkg/"},
+		{"include sees the query", Include(regexp.MustCompile(`\?page=\d+$`)), "", "https://golang.org/?page=2", ""},
+		{"exclude match", Exclude(regexp.MustCompile(`\.pdf$`)), "", "https://golang.org/spec.pdf", `matches excluded \.pdf$`},
+		{"exclude no match", Exclude(regexp.MustCompile(`\.pdf$`)), "", "https://golang.org/spec.html", ""},
+
+		{"all of, all allow", AllOf(SameHost(), PathPrefixes("/pkg/")), "https://golang.org/", "https://golang.org/pkg/", ""},
+		{"all of, first rejection", AllOf(SameHost(), PathPrefixes("/pkg/")), "https://golang.org/", "https://go.dev/cmd/", "host go.dev is not golang.org"},
+		{"all of, second rejection", AllOf(SameHost(), PathPrefixes("/pkg/")), "https://golang.org/", "https://golang.org/cmd/", "path /cmd/ is outside [/pkg/]"},
+		{"all of nothing", AllOf(), "", "https://golang.org/", ""},
+		{"any of, one allows", AnyOf(AllowDomains("go.dev"), PathPrefixes("/pkg/")), "", "https://golang.org/pkg/", ""},
+		{"any of, none allow", AnyOf(AllowDomains("go.dev"), PathPrefixes("/pkg/")), "", "https://golang.org/cmd/",
+			"domain golang.org is not allowed and path /cmd/ is outside [/pkg/]"},
+		{"nested", AllOf(DenyDomains("blog.golang.org"), AnyOf(PathPrefixes("/pkg/"), Include(regexp.MustCompile(`/doc/`)))),
+			"", "https://golang.org/doc/", ""},
+		{"nested, denied", AllOf(DenyDomains("blog.golang.org"), AnyOf(PathPrefixes("/pkg/"), Include(regexp.MustCompile(`/doc/`)))),
+			"", "https://blog.golang.org/pkg/", "domain blog.golang.org is denied"},
+	}
+	for _, test := range tests {
+		if got := test.rule(mustParse(t, test.from), mustParse(t, test.target)); got != test.want {
+			t.Errorf("%s: %s -> %s rejected with %q, want %q", test.name, test.from, test.target, got, test.want)
+		}
+	}
+}
+
+func TestCrawlScopeAllow(t *testing.T) {
+	// A nil scope follows everything, and records nothing.
+	var none *CrawlScope
+	if !none.Allow("", "https://golang.org/") || !none.takePage("", "https://golang.org/") || none.Filtered() != nil {
+		t.Error("nil scope filtered a link")
+	}
+
+	scope := &CrawlScope{Rules: []ScopeRule{SameHost(), PathPrefixes("/pkg/")}, MaxPages: 1}
+	if !scope.Allow("", "https://golang.org/pkg/") {
+		t.Error("seed filtered")
+	}
+	if scope.Allow("https://golang.org/pkg/", "https://go.dev/pkg/") {
+		t.Error("other host allowed")
+	}
+	if scope.Allow("https://golang.org/pkg/", "http://[::1") {
+		t.Error("bad URL allowed")
+	}
+	if !scope.takePage("", "https://golang.org/pkg/") || scope.takePage("https://golang.org/pkg/", "https://golang.org/pkg/os/") {
+		t.Error("MaxPages of 1 did not allow exactly one page")
+	}
+	want := []FilteredURL{
+		{"https://golang.org/pkg/", "https://go.dev/pkg/", "host go.dev is not golang.org"},
+		{"https://golang.org/pkg/", "http://[::1", `parse "http://[::1": missing ']' in host`},
+		{"https://golang.org/pkg/", "https://golang.org/pkg/os/", "page limit of 1 reached"},
+	}
+	if got := scope.Filtered(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Filtered() = %v, want %v", got, want)
+	}
+}
+
+// TestCrawlFiltered crawls with a scope and checks every link it dropped.
+func TestCrawlFiltered(t *testing.T) {
+	sorted := func(filtered []FilteredURL) []FilteredURL {
+		sort.Slice(filtered, func(i, j int) bool {
+			if filtered[i].From != filtered[j].From {
+				return filtered[i].From < filtered[j].From
+			}
+			return filtered[i].URL < filtered[j].URL
+		})
+		return filtered
+	}
+
+	// Only /pkg/: the links back to the root and to /cmd/ are dropped, once
+	// for every page they are on.
+	outcomes := &SafeCounter{}
+	c := crawler{
+		fetcher:  FakeFetcherImpl,
+		cache:    &SafeCache{},
+		scope:    &CrawlScope{Rules: []ScopeRule{SameHost(), PathPrefixes("/pkg/")}},
+		outcomes: outcomes,
+	}
+	c.run("https://golang.org/pkg/", 4)
+	want := []FilteredURL{
+		{"https://golang.org/pkg/", "https://golang.org/", "path / is outside [/pkg/]"},
+		{"https://golang.org/pkg/", "https://golang.org/cmd/", "path /cmd/ is outside [/pkg/]"},
+		{"https://golang.org/pkg/fmt/", "https://golang.org/", "path / is outside [/pkg/]"},
+		{"https://golang.org/pkg/os/", "https://golang.org/", "path / is outside [/pkg/]"},
+	}
+	if got := sorted(c.scope.Filtered()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Filtered() = %v, want %v", got, want)
+	}
+	if got := outcomes.Value("filtered"); got != len(want) {
+		t.Errorf("counted %d filtered, want %d", got, len(want))
+	}
+
+	// A chain is fetched one page after the other, so MaxPages always stops
+	// it at the same page.
+	chain, err := LoadFakeFetcher("fixtures/chain.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = crawler{fetcher: chain, cache: &SafeCache{}, scope: &CrawlScope{MaxPages: 3}}
+	c.run("https://example.com/0/", 20)
+	want = []FilteredURL{
+		{"https://example.com/2/", "https://example.com/3/", "page limit of 3 reached"},
+	}
+	if got := c.scope.Filtered(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Filtered() with MaxPages = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// LinkCache is a Cache that also remembers the links found on each page. A
+// crawl over a LinkCache left by an earlier run can follow the links of
+// pages it already has, instead of fetching them again.
+type LinkCache interface {
+	Cache
+	// AddPage stores the body and links of url in one step.
+	AddPage(url string, body string, urls []string)
+	// Links returns the links stored for url, if AddPage stored any.
+	Links(url string) (urls []string, ok bool)
+}
+
+// diskRecord is one line of the DiskCache log.
+type diskRecord struct {
+	URL  string `json:"url"`
+	Body string `json:"body"`
+	// URLs is null when the page was stored with Add, and a list (maybe
+	// empty) when stored with AddPage.
+	URLs []string `json:"urls"`
+}
+
+// compactSlack is how many stale records the log may hold beyond the live ones
+// before Add rewrites it.
+const compactSlack = 64
+
+// DiskCache is a Cache backed by an append-only log file, so it survives the
+// process dying mid-crawl. Every Add appends one line
+//
+//	<crc32 of json in hex> <json of diskRecord>
+//
+// and a later Add for the same url wins. When the process dies during a
+// write, the last line is cut short or fails its checksum; OpenDiskCache drops
+// it and everything after it. Once most lines are stale, the log is compacted
+// into a new file holding one line per url.
+//
+// Writes are not fsynced, so they survive the process dying but maybe not the
+// machine losing power.
+type DiskCache struct {
+	path    string
+	file    *os.File
+	pages   map[string]diskRecord
+	records int
+	// err is the first write error. Add has no error result, see Err.
+	err error
+	// compactErr is why the last compaction failed, nil if it worked. See
+	// CompactErr.
+	compactErr error
+	// retryAt is the record count at which a failed compaction is retried.
+	retryAt int
+	mux     sync.Mutex
+}
+
+// OpenDiskCache opens the log at path, creating it if needed, and loads
+// every intact record.
+func OpenDiskCache(path string) (*DiskCache, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cache := &DiskCache{path: path, file: file, pages: make(map[string]diskRecord)}
+	good, err := cache.load(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// Cut off a torn tail, so new records don't get appended after garbage.
+	if err := file.Truncate(good); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(good, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return cache, nil
+}
+
+// load reads records from r until the first damaged one, and returns the
+// offset just past the last good record.
+func (cache *DiskCache) load(r io.Reader) (int64, error) {
+	reader := bufio.NewReader(r)
+	var good int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without a newline was cut short.
+			return good, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		record, ok := decodeRecord(line)
+		if !ok {
+			return good, nil
+		}
+		cache.pages[record.URL] = record
+		cache.records++
+		good += int64(len(line))
+	}
+}
+
+func encodeRecord(record diskRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)), nil
+}
+
+func decodeRecord(line []byte) (record diskRecord, ok bool) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) < 9 || line[8] != ' ' {
+		return record, false
+	}
+	var sum uint32
+	if _, err := fmt.Sscanf(string(line[:8]), "%08x", &sum); err != nil {
+		return record, false
+	}
+	data := line[9:]
+	if crc32.ChecksumIEEE(data) != sum {
+		return record, false
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, false
+	}
+	return record, true
+}
+
+// write stores record and appends it to the log. Callers hold mux.
+func (cache *DiskCache) write(record diskRecord) {
+	cache.pages[record.URL] = record
+	if cache.err != nil {
+		return
+	}
+	line, err := encodeRecord(record)
+	if err == nil {
+		_, err = cache.file.Write(line)
+	}
+	if err != nil {
+		cache.err = err
+		return
+	}
+	cache.records++
+	if cache.records > 2*len(cache.pages)+compactSlack && cache.records >= cache.retryAt {
+		if err := cache.compact(); err != nil {
+			// The old log is still whole, so keep appending to it, and try
+			// again once it has grown some more. CompactErr reports it.
+			cache.retryAt = cache.records + compactSlack
+		}
+	}
+}
+
+// Add safely to cache.
+func (cache *DiskCache) Add(url string, body string) {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	cache.write(diskRecord{URL: url, Body: body})
+}
+
+// AddPage stores the body and links of url.
+func (cache *DiskCache) AddPage(url string, body string, urls []string) {
+	if urls == nil {
+		// Keep "no links" apart from "links not stored".
+		urls = []string{}
+	}
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	cache.write(diskRecord{URL: url, Body: body, URLs: urls})
+}
+
+// Get from cache.
+func (cache *DiskCache) Get(url string) (body string, ok bool) {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	record, ok := cache.pages[url]
+	return record.Body, ok
+}
+
+// Links returns the links stored for url by AddPage.
+func (cache *DiskCache) Links(url string) (urls []string, ok bool) {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	record, ok := cache.pages[url]
+	if !ok || record.URLs == nil {
+		return nil, false
+	}
+	return record.URLs, true
+}
+
+// Compact rewrites the log with a single record per url. If it fails, the
+// old log is left as it was, and the cache keeps appending to it.
+func (cache *DiskCache) Compact() error {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	if cache.err != nil {
+		return cache.err
+	}
+	return cache.compact()
+}
+
+// compact writes the live records to a new file and renames it over the log,
+// so a crash during compaction leaves either the old or the new log whole.
+func (cache *DiskCache) compact() (err error) {
+	defer func() {
+		cache.compactErr = err
+	}()
+	tmpPath := cache.path + ".compact"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+	writer := bufio.NewWriter(tmp)
+	for _, record := range cache.pages {
+		line, err := encodeRecord(record)
+		if err == nil {
+			_, err = writer.Write(line)
+		}
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, cache.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	cache.file.Close()
+	// tmp is now the log, and its offset is at the end, ready to append.
+	cache.file = tmp
+	cache.records = len(cache.pages)
+	cache.retryAt = 0
+	return nil
+}
+
+// Err returns the first error hit while appending to the log. After an error
+// the cache keeps working in memory, but stops writing. A failed compaction
+// is not such an error, it is retried later; see CompactErr.
+func (cache *DiskCache) Err() error {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	return cache.err
+}
+
+// CompactErr returns why the last compaction failed, or nil if it worked or
+// none was needed yet. Compactions run as the log grows, from Add and
+// AddPage, which have no error result.
+func (cache *DiskCache) CompactErr() error {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	return cache.compactErr
+}
+
+// Close closes the log file.
+func (cache *DiskCache) Close() error {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	return cache.file.Close()
+}
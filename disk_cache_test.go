@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func openTestCache(t *testing.T, path string) *DiskCache {
+	t.Helper()
+	cache, err := OpenDiskCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+// lineCount returns the number of records in the log at path.
+func lineCount(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestDiskCacheReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	cache := openTestCache(t, path)
+	cache.Add("https://golang.org/", "old")
+	cache.Add("https://golang.org/", "Go")
+	cache.AddPage("https://golang.org/pkg/", "Packages", []string{"https://golang.org/"})
+	cache.AddPage("https://golang.org/cmd/", "Commands", nil)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache = openTestCache(t, path)
+	defer cache.Close()
+	if body, ok := cache.Get("https://golang.org/"); !ok || body != "Go" {
+		t.Errorf("Get = %q, %v, want the last Add", body, ok)
+	}
+	if _, ok := cache.Links("https://golang.org/"); ok {
+		t.Error("Links of a page stored with Add")
+	}
+	if urls, ok := cache.Links("https://golang.org/pkg/"); !ok || !reflect.DeepEqual(urls, []string{"https://golang.org/"}) {
+		t.Errorf("Links = %v, %v", urls, ok)
+	}
+	// No links is not the same as links not stored.
+	if urls, ok := cache.Links("https://golang.org/cmd/"); !ok || len(urls) != 0 {
+		t.Errorf("Links of a page without links = %v, %v", urls, ok)
+	}
+}
+
+// TestDiskCacheTruncated cuts the log at random points, as if the process
+// died mid-write, and checks that every record before the cut survives.
+func TestDiskCacheTruncated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.log")
+	cache := openTestCache(t, path)
+	// offsets[i] is where record i ends, and states[i] is what the cache
+	// should hold once records 0..i are read back.
+	var offsets []int64
+	var states []map[string]string
+	want := make(map[string]string)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 40; i++ {
+		url := fmt.Sprintf("https://golang.org/%d/", random.Intn(10))
+		body := fmt.Sprintf("body %d with \"quotes\" and\nnewlines", i)
+		cache.Add(url, body)
+		want[url] = body
+		info, err := cache.file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, info.Size())
+		snapshot := make(map[string]string, len(want))
+		for url, body := range want {
+			snapshot[url] = body
+		}
+		states = append(states, snapshot)
+	}
+	cache.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != offsets[len(offsets)-1] {
+		t.Fatalf("log is %d bytes, want %d", len(data), offsets[len(offsets)-1])
+	}
+
+	for trial := 0; trial < 200; trial++ {
+		cut := random.Int63n(int64(len(data)) + 1)
+		cutPath := filepath.Join(dir, fmt.Sprintf("cut%d.log", trial))
+		if err := os.WriteFile(cutPath, data[:cut], 0644); err != nil {
+			t.Fatal(err)
+		}
+		wantState := map[string]string{}
+		for i, offset := range offsets {
+			if offset <= cut {
+				wantState = states[i]
+			}
+		}
+
+		cache := openTestCache(t, cutPath)
+		for url, body := range wantState {
+			if got, ok := cache.Get(url); !ok || got != body {
+				t.Fatalf("cut at %d: Get(%q) = %q, %v, want %q", cut, url, got, ok, body)
+			}
+		}
+		if len(cache.pages) != len(wantState) {
+			t.Fatalf("cut at %d: %d pages, want %d", cut, len(cache.pages), len(wantState))
+		}
+		// The torn tail is gone, so new records are readable after a reopen.
+		cache.Add("https://golang.org/after/", "after")
+		cache.Close()
+		cache = openTestCache(t, cutPath)
+		if got, ok := cache.Get("https://golang.org/after/"); !ok || got != "after" {
+			t.Fatalf("cut at %d: record added after reopening was lost", cut)
+		}
+		if len(cache.pages) != len(wantState)+1 {
+			t.Fatalf("cut at %d: %d pages after Add, want %d", cut, len(cache.pages), len(wantState)+1)
+		}
+		cache.Close()
+	}
+}
+
+func TestDiskCacheCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	cache := openTestCache(t, path)
+	cache.Add("a", "A")
+	cache.Add("b", "B")
+	cache.Add("c", "C")
+	cache.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a byte in the second record: its checksum no longer matches, so
+	// it and everything after it are dropped.
+	second := bytes.IndexByte(data, '\n') + 1
+	data[second+12] ^= 1
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache = openTestCache(t, path)
+	defer cache.Close()
+	if got := diskBodies(cache); got != "A" {
+		t.Errorf("after corruption the cache holds %q, want only A", got)
+	}
+}
+
+// diskBodies returns the bodies of a, b and c, in order.
+func diskBodies(cache *DiskCache) string {
+	s := ""
+	for _, url := range []string{"a", "b", "c"} {
+		body, _ := cache.Get(url)
+		s += body
+	}
+	return s
+}
+
+func TestDiskCacheCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	cache := openTestCache(t, path)
+	for i := 0; i < 200; i++ {
+		cache.Add("https://golang.org/", fmt.Sprint(i))
+	}
+	if err := cache.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := lineCount(t, path); n > 2+compactSlack {
+		t.Errorf("log has %d records for 1 url, want it compacted", n)
+	}
+	if err := cache.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if n := lineCount(t, path); n != 1 {
+		t.Errorf("log has %d records after Compact, want 1", n)
+	}
+	cache.Close()
+	cache = openTestCache(t, path)
+	defer cache.Close()
+	if body, _ := cache.Get("https://golang.org/"); body != "199" {
+		t.Errorf("Get after compaction = %q, want 199", body)
+	}
+}
+
+func TestDiskCacheCompactionFailureIsRetried(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	// A directory where the compacted log goes makes compaction fail.
+	if err := os.Mkdir(path+".compact", 0755); err != nil {
+		t.Fatal(err)
+	}
+	cache := openTestCache(t, path)
+	for i := 0; i < 100; i++ {
+		cache.Add("https://golang.org/", fmt.Sprint(i))
+	}
+	// The compactions Add started failed quietly, and say so in CompactErr.
+	if err := cache.CompactErr(); err == nil {
+		t.Error("CompactErr() = nil with a directory in the way")
+	}
+	if err := cache.Compact(); err == nil {
+		t.Error("Compact succeeded with a directory in the way")
+	}
+	// Appending carries on regardless.
+	if err := cache.Err(); err != nil {
+		t.Fatalf("Err() = %v after a failed compaction", err)
+	}
+	if n := lineCount(t, path); n != 100 {
+		t.Fatalf("log has %d records, want all 100", n)
+	}
+
+	if err := os.Remove(path + ".compact"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 100; i < 200; i++ {
+		cache.Add("https://golang.org/", fmt.Sprint(i))
+	}
+	if n := lineCount(t, path); n > 2+compactSlack {
+		t.Errorf("log has %d records, want compaction to have been retried", n)
+	}
+	if err := cache.CompactErr(); err != nil {
+		t.Errorf("CompactErr() = %v after the retry worked", err)
+	}
+	cache.Close()
+	cache = openTestCache(t, path)
+	defer cache.Close()
+	if body, _ := cache.Get("https://golang.org/"); body != "199" {
+		t.Errorf("Get after reopen = %q, want 199", body)
+	}
+}
+
+// TestCrawlResumesFromDiskCache stops a crawl after two pages, then crawls
+// again over the same log: only the missing pages are fetched.
+func TestCrawlResumesFromDiskCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	cache := openTestCache(t, path)
+	first := NewRecordingFetcher(FakeFetcherImpl)
+	interrupted := crawler{fetcher: first, cache: cache, scope: &CrawlScope{MaxPages: 2}}
+	interrupted.run("https://golang.org/", 4)
+	cache.Close()
+
+	cache = openTestCache(t, path)
+	defer cache.Close()
+	second := NewRecordingFetcher(FakeFetcherImpl)
+	crawl("https://golang.org/", 4, second, cache)
+	// Failed fetches are not cached, so they are tried again.
+	for url := range second.Archive() {
+		if record, ok := first.Archive()[url]; ok && record.Error == "" {
+			t.Errorf("%s was fetched again", url)
+		}
+	}
+	for url := range FakeFetcherImpl {
+		if _, ok := cache.Get(url); !ok {
+			t.Errorf("%s is missing after the resumed crawl", url)
+		}
+	}
+}
@@ -1,13 +1,27 @@
 // Programs start running in main package.
 package main
 
-import "fmt"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var check = flag.Bool("check", false, "run the concurrency demos under a deadlock and leak watchdog")
 
 func printBanner(s string) {
 	fmt.Printf("================= BEGIN %s =================\n", s)
 }
 
 func main() {
+	flag.Parse()
+	if *check {
+		if !CheckDemos(30 * time.Second) {
+			os.Exit(1)
+		}
+		return
+	}
 	printBanner("Basics")
 	BasicsMain()
 	printBanner("Flow Control")
@@ -20,4 +34,8 @@ func main() {
 	ConcurrencyMain()
 	printBanner("Concurrency2")
 	TalkingGophers()
+	printBanner("Search")
+	SearchMain()
+	printBanner("PubSub")
+	BrokerMain()
 }
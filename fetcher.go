@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"sync"
+	"time"
 )
 
 // Fetcher is an example of an HTTP web page crawler.
@@ -18,28 +18,37 @@ type FakeFetcher map[string]*FakeResult
 type FakeResult struct {
 	body string
 	urls []string
+	// err, if set, is returned instead of the body. e.g. "500 internal error".
+	err string
+	// latency is how long Fetch sleeps before answering.
+	latency time.Duration
 }
 
 // Fetch returns the body of URL and a slice of URLs found on that page.
 func (f FakeFetcher) Fetch(url string) (string, []string, error) {
 	if res, ok := f[url]; ok {
+		time.Sleep(res.latency)
+		if res.err != "" {
+			return "", nil, fmt.Errorf("%s: %s", res.err, url)
+		}
 		return res.body, res.urls, nil
 	}
 	return "", nil, fmt.Errorf("not found: %s", url)
 }
 
-// FakeFetcherImpl is a populated FakeFetcher.
+// FakeFetcherImpl is a populated FakeFetcher. It has the same pages as
+// fixtures/golang.json, and a test keeps the two in step.
 var FakeFetcherImpl = FakeFetcher{
 	"https://golang.org/": &FakeResult{
-		"The Go Programming Language",
-		[]string{
+		body: "The Go Programming Language",
+		urls: []string{
 			"https://golang.org/pkg/",
 			"https://golang.org/cmd/",
 		},
 	},
 	"https://golang.org/pkg/": &FakeResult{
-		"Packages",
-		[]string{
+		body: "Packages",
+		urls: []string{
 			"https://golang.org/",
 			"https://golang.org/cmd/",
 			"https://golang.org/pkg/fmt/",
@@ -47,39 +56,17 @@ var FakeFetcherImpl = FakeFetcher{
 		},
 	},
 	"https://golang.org/pkg/fmt/": &FakeResult{
-		"Package fmt",
-		[]string{
+		body: "Package fmt",
+		urls: []string{
 			"https://golang.org/",
 			"https://golang.org/pkg/",
 		},
 	},
 	"https://golang.org/pkg/os/": &FakeResult{
-		"Package os",
-		[]string{
+		body: "Package os",
+		urls: []string{
 			"https://golang.org/",
 			"https://golang.org/pkg/",
 		},
 	},
 }
-
-// SafeCache is a map of url to HTTP body, safe for concurrent use.
-type SafeCache struct {
-	// Map of url to body.
-	cache map[string]string
-	mux   sync.Mutex
-}
-
-// Add safely to cache.
-func (safeCache *SafeCache) Add(url string, body string) {
-	safeCache.mux.Lock()
-	defer safeCache.mux.Unlock()
-	safeCache.cache[url] = body
-}
-
-// Get from cache.
-func (safeCache *SafeCache) Get(url string) (body string, ok bool) {
-	safeCache.mux.Lock()
-	defer safeCache.mux.Unlock()
-	body, ok = safeCache.cache[url]
-	return
-}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fakeFixture is how a FakeResult is spelled in a fixture file:
+//
+//	{
+//	  "https://golang.org/": {
+//	    "body": "The Go Programming Language",
+//	    "urls": ["https://golang.org/pkg/"],
+//	    "error": "500 internal error",
+//	    "latency": "50ms"
+//	  }
+//	}
+//
+// error and latency are optional.
+type fakeFixture struct {
+	Body    string   `json:"body"`
+	URLs    []string `json:"urls"`
+	Error   string   `json:"error,omitempty"`
+	Latency string   `json:"latency,omitempty"`
+}
+
+// ReadFakeFetcher decodes a JSON fixture of URL -> page into a FakeFetcher.
+// URLs are normalized the way the crawler looks them up, so a fixture can
+// spell them however it likes.
+func ReadFakeFetcher(r io.Reader) (FakeFetcher, error) {
+	var fixtures map[string]fakeFixture
+	dec := json.NewDecoder(r)
+	// Catch typos like "url" instead of silently ignoring them.
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fixtures); err != nil {
+		return nil, err
+	}
+	fetcher := make(FakeFetcher, len(fixtures))
+	for url, fixture := range fixtures {
+		var latency time.Duration
+		if fixture.Latency != "" {
+			var err error
+			if latency, err = time.ParseDuration(fixture.Latency); err != nil {
+				return nil, fmt.Errorf("%s: bad latency: %v", url, err)
+			}
+		}
+		key := normalizeURL(url)
+		if _, ok := fetcher[key]; ok {
+			return nil, fmt.Errorf("%s: another fixture is also for %s", url, key)
+		}
+		urls := make([]string, len(fixture.URLs))
+		for i, u := range fixture.URLs {
+			urls[i] = normalizeURL(u)
+		}
+		fetcher[key] = &FakeResult{fixture.Body, urls, fixture.Error, latency}
+	}
+	return fetcher, nil
+}
+
+// LoadFakeFetcher reads a JSON fixture file, see ReadFakeFetcher.
+func LoadFakeFetcher(path string) (FakeFetcher, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fetcher, err := ReadFakeFetcher(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return fetcher, nil
+}
+
+// Validate returns an error listing every link to a URL that has no fixture.
+// Those links fail with "not found" when crawled, which is fine for a broken
+// link scenario, but usually means a typo. URLs are compared normalized, like
+// the crawler compares them, and fixtures whose URL isn't normalized are
+// listed too, since the crawler never asks for them.
+func (f FakeFetcher) Validate() error {
+	known := make(map[string]bool, len(f))
+	var missing []string
+	for url := range f {
+		normalized := normalizeURL(url)
+		known[normalized] = true
+		// The crawler only ever asks for normalized URLs.
+		if normalized != url {
+			missing = append(missing, fmt.Sprintf("%s is never fetched, the crawler asks for %s", url, normalized))
+		}
+	}
+	for from, res := range f {
+		for _, to := range res.urls {
+			if !known[normalizeURL(to)] {
+				missing = append(missing, fmt.Sprintf("%s links to %s, which has no fixture", from, to))
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("%d fixture problems:\n%s", len(missing), strings.Join(missing, "\n"))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReadFakeFetcherNormalizes(t *testing.T) {
+	fetcher, err := ReadFakeFetcher(strings.NewReader(`{
+		"https://EXAMPLE.com:443/a#top": {"body": "A", "urls": ["https://example.com/x/../b"]},
+		"https://example.com/b": {"body": "B", "urls": []}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fetcher.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	cache := &SafeCache{}
+	crawl("https://example.com/a", 2, fetcher, cache)
+	for url, want := range map[string]string{"https://example.com/a": "A", "https://example.com/b": "B"} {
+		if got, ok := cache.Get(url); !ok || got != want {
+			t.Errorf("cache.Get(%q) = %q, %v, want %q", url, got, ok, want)
+		}
+	}
+}
+
+// TestFakeFetcherImplMatchesFixture keeps the literal in fetcher.go, which
+// the tour demos use, the same as fixtures/golang.json.
+func TestFakeFetcherImplMatchesFixture(t *testing.T) {
+	fixture, err := LoadFakeFetcher("fixtures/golang.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(FakeFetcherImpl, fixture) {
+		for url := range fixture {
+			if !reflect.DeepEqual(FakeFetcherImpl[url], fixture[url]) {
+				t.Errorf("%s: FakeFetcherImpl has %+v, golang.json %+v", url, FakeFetcherImpl[url], fixture[url])
+			}
+		}
+		for url := range FakeFetcherImpl {
+			if fixture[url] == nil {
+				t.Errorf("%s: in FakeFetcherImpl, not in golang.json", url)
+			}
+		}
+	}
+}
+
+func TestReadFakeFetcherErrors(t *testing.T) {
+	for name, fixture := range map[string]string{
+		"unknown field": `{"https://example.com/": {"url": "typo"}}`,
+		"bad latency":   `{"https://example.com/": {"latency": "soon"}}`,
+		"duplicate":     `{"https://example.com/": {}, "https://EXAMPLE.com/": {}}`,
+		"not json":      `https://example.com/`,
+	} {
+		if _, err := ReadFakeFetcher(strings.NewReader(fixture)); err == nil {
+			t.Errorf("%s: ReadFakeFetcher returned no error", name)
+		}
+	}
+}
+
+func TestFakeFetcherValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fetcher FakeFetcher
+		// want are substrings of the error, none means no error.
+		want []string
+	}{
+		{"complete", FakeFetcher{
+			"https://example.com/": {urls: []string{"https://EXAMPLE.com/#top"}},
+		}, nil},
+		{"missing link", FakeFetcher{
+			"https://example.com/": {urls: []string{"https://example.com/gone/"}},
+		}, []string{"https://example.com/ links to https://example.com/gone/"}},
+		{"key not normalized", FakeFetcher{
+			"https://EXAMPLE.com/": {},
+		}, []string{"https://EXAMPLE.com/ is never fetched"}},
+	}
+	for _, test := range tests {
+		err := test.fetcher.Validate()
+		if (err != nil) != (len(test.want) > 0) {
+			t.Errorf("%s: Validate() = %v", test.name, err)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: Validate() = %v, want it to mention %q", test.name, err, want)
+			}
+		}
+	}
+}
+
+// TestCrawlFixtures crawls every scenario in fixtures/, and checks which
+// pages end up in the cache.
+func TestCrawlFixtures(t *testing.T) {
+	tests := []struct {
+		file  string
+		seed  string
+		depth int
+		// valid is whether every link has a fixture.
+		valid bool
+		want  []string
+	}{
+		{"golang.json", "https://golang.org/", 4, false, []string{
+			"https://golang.org/", "https://golang.org/pkg/",
+			"https://golang.org/pkg/fmt/", "https://golang.org/pkg/os/",
+		}},
+		// A cycle ends once every page is cached, however deep the crawl.
+		{"cycle.json", "https://example.com/a/", 100, true, []string{
+			"https://example.com/a/", "https://example.com/b/", "https://example.com/c/",
+		}},
+		// A chain is cut off at the maximum depth.
+		{"chain.json", "https://example.com/0/", 3, true, []string{
+			"https://example.com/0/", "https://example.com/1/", "https://example.com/2/",
+		}},
+		{"chain.json", "https://example.com/0/", 20, true, []string{
+			"https://example.com/0/", "https://example.com/1/", "https://example.com/2/",
+			"https://example.com/3/", "https://example.com/4/", "https://example.com/5/",
+			"https://example.com/6/", "https://example.com/7/", "https://example.com/8/",
+			"https://example.com/9/",
+		}},
+		// Errors and missing pages are not cached.
+		{"broken.json", "https://example.com/", 3, false, []string{
+			"https://example.com/", "https://example.com/ok/",
+		}},
+	}
+	for _, test := range tests {
+		fetcher, err := LoadFakeFetcher("fixtures/" + test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fetcher.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s: Validate() = %v", test.file, err)
+		}
+		cache := &SafeCache{}
+		crawl(test.seed, test.depth, fetcher, cache)
+		var got []string
+		for url := range fetcher {
+			if _, ok := cache.Get(url); ok {
+				got = append(got, url)
+			}
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") || cache.Len() != len(test.want) {
+			t.Errorf("%s depth %d cached %v, want %v", test.file, test.depth, got, test.want)
+		}
+	}
+}
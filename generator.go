@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"math/big"
+)
+
+// Unfold is channelSelect made general: it emits the values of step, run
+// over and over from initial, until ctx is cancelled. step gets the current
+// state and returns the next value and the next state.
+//
+// The channel is unbuffered, so step only runs when the reader is ready for
+// another value; a slow reader slows the generator down instead of piling up
+// values. To stop after n values, wrap it in Take (see pipeline.go) and
+// cancel ctx after.
+func Unfold[S, V any](ctx context.Context, initial S, step func(S) (V, S)) <-chan V {
+	out := make(chan V)
+	go func() {
+		defer close(out)
+		state := initial
+		for {
+			var v V
+			v, state = step(state)
+			if !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// fibState is a pair of consecutive Fibonacci numbers.
+type fibState struct {
+	x, y *big.Int
+}
+
+// fibonacciStep is the step of channelSelect, `x, y = y, x+y`, on big.Ints:
+// ints overflow after the 92nd Fibonacci number, big.Ints never do. Each step
+// makes a new big.Int, since the old ones were handed to the reader.
+func fibonacciStep(s fibState) (*big.Int, fibState) {
+	return s.x, fibState{s.y, new(big.Int).Add(s.x, s.y)}
+}
+
+// Fibonacci emits 0, 1, 1, 2, 3, 5, ... until ctx is cancelled.
+func Fibonacci(ctx context.Context) <-chan *big.Int {
+	return Unfold(ctx, fibState{big.NewInt(0), big.NewInt(1)}, fibonacciStep)
+}
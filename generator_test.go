@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFibonacci(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fibs := collect(Take(ctx, Fibonacci(ctx), 94))
+	if len(fibs) != 94 {
+		t.Fatalf("Take(94) gave %d values", len(fibs))
+	}
+	for i, want := range []int64{0, 1, 1, 2, 3, 5, 8, 13, 21, 34} {
+		if fibs[i].Int64() != want {
+			t.Errorf("F(%d) = %v, want %d", i, fibs[i], want)
+		}
+	}
+	// F(92) is the last one that fits in an int64, F(93) is past it.
+	if f92 := fibs[92]; !f92.IsInt64() || f92.Int64() != 7540113804746346429 {
+		t.Errorf("F(92) = %v, want 7540113804746346429", f92)
+	}
+	f93, _ := new(big.Int).SetString("12200160415121876738", 10)
+	if fibs[93].IsInt64() || fibs[93].Cmp(f93) != 0 {
+		t.Errorf("F(93) = %v, want %v", fibs[93], f93)
+	}
+	if fibs[93].Cmp(big.NewInt(math.MaxInt64)) <= 0 {
+		t.Errorf("F(93) = %v fits in an int64", fibs[93])
+	}
+	// Every value is its own big.Int, so the reader can keep them.
+	if fibs[1] == fibs[2] {
+		t.Error("F(1) and F(2) share a big.Int")
+	}
+}
+
+func TestUnfoldTake(t *testing.T) {
+	for _, n := range []int{0, 1, 5, 100} {
+		ctx, cancel := context.WithCancel(context.Background())
+		squares := Unfold(ctx, 0, func(i int) (int, int) { return i * i, i + 1 })
+		got := collect(Take(ctx, squares, n))
+		cancel()
+		if len(got) != n {
+			t.Errorf("Take(%d) gave %d values", n, len(got))
+		}
+		for i, v := range got {
+			if v != i*i {
+				t.Errorf("value %d = %d, want %d", i, v, i*i)
+			}
+		}
+	}
+}
+
+func TestUnfoldCancel(t *testing.T) {
+	err := Watch("Fibonacci", 5*time.Second, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		fibs := Fibonacci(ctx)
+		for i := 0; i < 10; i++ {
+			<-fibs
+		}
+		cancel()
+		// The generator is stuck on a send nobody reads; cancel still stops
+		// it, and it closes the channel on the way out.
+		for range fibs {
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUnfoldBackPressure checks that step runs only when the reader wants a
+// value: at most one ahead, the value waiting to be sent.
+func TestUnfoldBackPressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var steps atomic.Int64
+	counting := Unfold(ctx, 0, func(i int) (int, int) {
+		steps.Add(1)
+		return i, i + 1
+	})
+	for read := 1; read <= 5; read++ {
+		<-counting
+		// A generator that runs ahead would have plenty of time to here.
+		time.Sleep(20 * time.Millisecond)
+		if got := steps.Load(); got > int64(read)+1 {
+			t.Fatalf("step ran %d times after %d reads", got, read)
+		}
+	}
+}
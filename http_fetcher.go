@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// hrefPattern finds links in a page. A regexp is not a real HTML parser, but
+// it is good enough for simple sites, like the ones in the demos.
+var hrefPattern = regexp.MustCompile(`href="([^"]*)"`)
+
+// HTTPFetcher is a Fetcher that does real HTTP GET requests.
+type HTTPFetcher struct {
+	// Client is used for the requests. nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// Fetch returns the body of URL and the absolute URLs of its links.
+func (f HTTPFetcher) Fetch(rawURL string) (string, []string, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("%s: %s", resp.Status, rawURL)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Links are relative to the page they are on.
+	base := resp.Request.URL
+	var urls []string
+	for _, match := range hrefPattern.FindAllStringSubmatch(string(body), -1) {
+		ref, err := url.Parse(match[1])
+		if err != nil {
+			continue
+		}
+		urls = append(urls, base.ResolveReference(ref).String())
+	}
+	return string(body), urls, nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Edge is one link found while crawling.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Depth is the crawl depth left at From, as passed to crawl. Links on the
+	// seed page have the largest depth.
+	Depth int `json:"depth"`
+}
+
+// Page is a URL seen by the crawler, and the error from fetching it if there
+// was one. Pages that were linked to but never fetched, because they were out
+// of scope or past the maximum depth, have Fetched false.
+type Page struct {
+	URL     string `json:"url"`
+	Fetched bool   `json:"fetched"`
+	Error   string `json:"error,omitempty"`
+}
+
+// LinkGraph records the pages and links seen by the crawler. It is safe for
+// concurrent use, since every crawl goroutine writes to the same graph.
+type LinkGraph struct {
+	mux   sync.Mutex
+	pages map[string]Page
+	edges []Edge
+}
+
+// NewLinkGraph returns an empty graph.
+func NewLinkGraph() *LinkGraph {
+	return &LinkGraph{pages: make(map[string]Page)}
+}
+
+// AddPage records the result of fetching url.
+func (g *LinkGraph) AddPage(url string, err error) {
+	page := Page{URL: url, Fetched: true}
+	if err != nil {
+		page.Error = err.Error()
+	}
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.pages[url] = page
+}
+
+// AddEdge records a link from one page to another. Either page is added as
+// not fetched yet if it is new.
+func (g *LinkGraph) AddEdge(from, to string, depth int) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.edges = append(g.edges, Edge{from, to, depth})
+	for _, url := range []string{from, to} {
+		if _, ok := g.pages[url]; !ok {
+			g.pages[url] = Page{URL: url}
+		}
+	}
+}
+
+// snapshot returns a sorted copy of the pages and edges, so every export of
+// the same crawl comes out the same no matter how the goroutines interleaved.
+func (g *LinkGraph) snapshot() ([]Page, []Edge) {
+	g.mux.Lock()
+	pages := make([]Page, 0, len(g.pages))
+	for _, page := range g.pages {
+		pages = append(pages, page)
+	}
+	edges := append([]Edge(nil), g.edges...)
+	g.mux.Unlock()
+
+	sort.Slice(pages, func(i, j int) bool { return pages[i].URL < pages[j].URL })
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		if edges[i].To != edges[j].To {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].Depth > edges[j].Depth
+	})
+	return pages, edges
+}
+
+// WriteJSON writes the graph as {"pages": [...], "edges": [...]}.
+func (g *LinkGraph) WriteJSON(w io.Writer) error {
+	pages, edges := g.snapshot()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Pages []Page `json:"pages"`
+		Edges []Edge `json:"edges"`
+	}{pages, edges})
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. Pages that failed to fetch
+// are drawn in red, and pages never fetched dashed. Render with
+// `dot -Tsvg crawl.dot > crawl.svg`.
+func (g *LinkGraph) WriteDOT(w io.Writer) error {
+	pages, edges := g.snapshot()
+	if _, err := fmt.Fprintln(w, "digraph crawl {"); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		var err error
+		switch {
+		case !page.Fetched:
+			_, err = fmt.Fprintf(w, "  %q [style=dashed];\n", page.URL)
+		case page.Error != "":
+			_, err = fmt.Fprintf(w, "  %q [color=red];\n", page.URL)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, edge := range edges {
+		if _, err := fmt.Fprintf(w, "  %q -> %q [label=%d];\n", edge.From, edge.To, edge.Depth); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// WriteAdjacency writes one line per page, "from: to1 to2 ...". Duplicate
+// links are only listed once.
+func (g *LinkGraph) WriteAdjacency(w io.Writer) error {
+	_, edges := g.snapshot()
+	for i := 0; i < len(edges); {
+		from := edges[i].From
+		if _, err := fmt.Fprintf(w, "%s:", from); err != nil {
+			return err
+		}
+		last := ""
+		for ; i < len(edges) && edges[i].From == from; i++ {
+			if edges[i].To == last {
+				continue
+			}
+			last = edges[i].To
+			if _, err := fmt.Fprintf(w, " %s", last); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LinkGraphStats are simple measurements of a crawled graph.
+type LinkGraphStats struct {
+	// InDegree and OutDegree count distinct links into and out of each URL.
+	InDegree, OutDegree map[string]int
+	// BrokenLinks are links to pages that failed to fetch.
+	BrokenLinks []Edge
+	// Unfetched are pages that were linked to but never fetched.
+	Unfetched []string
+	// Unreachable are recorded pages with no path to them from the seed. A
+	// single crawl only records what it reached, so this is only non-empty
+	// when the graph holds several crawls, or seed is not where it started.
+	Unreachable []string
+}
+
+// Stats computes LinkGraphStats, using seed as the start of the crawl. seed
+// is normalized the same way the crawler normalizes URLs.
+func (g *LinkGraph) Stats(seed string) LinkGraphStats {
+	seed = normalizeURL(seed)
+	pages, edges := g.snapshot()
+	stats := LinkGraphStats{
+		InDegree:  make(map[string]int),
+		OutDegree: make(map[string]int),
+	}
+	failed := make(map[string]bool)
+	for _, page := range pages {
+		if page.Error != "" {
+			failed[page.URL] = true
+		}
+		if !page.Fetched {
+			stats.Unfetched = append(stats.Unfetched, page.URL)
+		}
+	}
+
+	links := make(map[string][]string)
+	seen := make(map[Edge]bool)
+	for _, edge := range edges {
+		// The same link can be found at several depths, count it once.
+		key := Edge{From: edge.From, To: edge.To}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		stats.OutDegree[edge.From]++
+		stats.InDegree[edge.To]++
+		links[edge.From] = append(links[edge.From], edge.To)
+		if failed[edge.To] {
+			stats.BrokenLinks = append(stats.BrokenLinks, edge)
+		}
+	}
+
+	// Breadth first search from the seed.
+	reached := map[string]bool{seed: true}
+	queue := []string{seed}
+	for len(queue) > 0 {
+		url := queue[0]
+		queue = queue[1:]
+		for _, to := range links[url] {
+			if !reached[to] {
+				reached[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	for _, page := range pages {
+		if !reached[page.URL] {
+			stats.Unreachable = append(stats.Unreachable, page.URL)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testGraph is a → b, a → c, b → a, with c failing, d never fetched and e
+// fetched but not linked from anywhere.
+func testGraph() *LinkGraph {
+	g := NewLinkGraph()
+	g.AddPage("https://x.com/a/", nil)
+	g.AddPage("https://x.com/b/", nil)
+	g.AddPage("https://x.com/c/", fmt.Errorf("not found"))
+	g.AddPage("https://x.com/e/", nil)
+	g.AddEdge("https://x.com/b/", "https://x.com/a/", 1)
+	g.AddEdge("https://x.com/a/", "https://x.com/c/", 2)
+	g.AddEdge("https://x.com/a/", "https://x.com/b/", 2)
+	// The same link found again deeper down only counts once.
+	g.AddEdge("https://x.com/a/", "https://x.com/b/", 0)
+	g.AddEdge("https://x.com/b/", "https://x.com/d/", 1)
+	return g
+}
+
+func TestLinkGraphWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGraph().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "pages": [
+    {
+      "url": "https://x.com/a/",
+      "fetched": true
+    },
+    {
+      "url": "https://x.com/b/",
+      "fetched": true
+    },
+    {
+      "url": "https://x.com/c/",
+      "fetched": true,
+      "error": "not found"
+    },
+    {
+      "url": "https://x.com/d/",
+      "fetched": false
+    },
+    {
+      "url": "https://x.com/e/",
+      "fetched": true
+    }
+  ],
+  "edges": [
+    {
+      "from": "https://x.com/a/",
+      "to": "https://x.com/b/",
+      "depth": 2
+    },
+    {
+      "from": "https://x.com/a/",
+      "to": "https://x.com/b/",
+      "depth": 0
+    },
+    {
+      "from": "https://x.com/a/",
+      "to": "https://x.com/c/",
+      "depth": 2
+    },
+    {
+      "from": "https://x.com/b/",
+      "to": "https://x.com/a/",
+      "depth": 1
+    },
+    {
+      "from": "https://x.com/b/",
+      "to": "https://x.com/d/",
+      "depth": 1
+    }
+  ]
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteJSON =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLinkGraphWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGraph().WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph crawl {
+  "https://x.com/c/" [color=red];
+  "https://x.com/d/" [style=dashed];
+  "https://x.com/a/" -> "https://x.com/b/" [label=2];
+  "https://x.com/a/" -> "https://x.com/b/" [label=0];
+  "https://x.com/a/" -> "https://x.com/c/" [label=2];
+  "https://x.com/b/" -> "https://x.com/a/" [label=1];
+  "https://x.com/b/" -> "https://x.com/d/" [label=1];
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteDOT =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLinkGraphWriteAdjacency(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGraph().WriteAdjacency(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `https://x.com/a/: https://x.com/b/ https://x.com/c/
+https://x.com/b/: https://x.com/a/ https://x.com/d/
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteAdjacency =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLinkGraphStats(t *testing.T) {
+	// The seed is normalized, like the crawler does.
+	stats := testGraph().Stats("https://X.com/a/#top")
+	wantIn := map[string]int{"https://x.com/a/": 1, "https://x.com/b/": 1, "https://x.com/c/": 1, "https://x.com/d/": 1}
+	wantOut := map[string]int{"https://x.com/a/": 2, "https://x.com/b/": 2}
+	if !reflect.DeepEqual(stats.InDegree, wantIn) {
+		t.Errorf("InDegree = %v, want %v", stats.InDegree, wantIn)
+	}
+	if !reflect.DeepEqual(stats.OutDegree, wantOut) {
+		t.Errorf("OutDegree = %v, want %v", stats.OutDegree, wantOut)
+	}
+	wantBroken := []Edge{{"https://x.com/a/", "https://x.com/c/", 2}}
+	if !reflect.DeepEqual(stats.BrokenLinks, wantBroken) {
+		t.Errorf("BrokenLinks = %v, want %v", stats.BrokenLinks, wantBroken)
+	}
+	if want := []string{"https://x.com/d/"}; !reflect.DeepEqual(stats.Unfetched, want) {
+		t.Errorf("Unfetched = %v, want %v", stats.Unfetched, want)
+	}
+	if want := []string{"https://x.com/e/"}; !reflect.DeepEqual(stats.Unreachable, want) {
+		t.Errorf("Unreachable = %v, want %v", stats.Unreachable, want)
+	}
+}
+
+func TestCrawlRecordsLinkGraph(t *testing.T) {
+	c := crawler{fetcher: FakeFetcherImpl, cache: &SafeCache{}, graph: NewLinkGraph()}
+	c.run("https://GOLANG.org", 2)
+	stats := c.graph.Stats("https://golang.org")
+	// Links on the depth 1 pages are recorded but not fetched.
+	if want := []string{"https://golang.org/pkg/fmt/", "https://golang.org/pkg/os/"}; !reflect.DeepEqual(stats.Unfetched, want) {
+		t.Errorf("Unfetched = %v, want %v", stats.Unfetched, want)
+	}
+	// Everything was found from the seed.
+	if len(stats.Unreachable) != 0 {
+		t.Errorf("Unreachable = %v, want none", stats.Unreachable)
+	}
+	// /cmd/ has no fixture, so both links to it are broken.
+	wantBroken := []Edge{
+		{"https://golang.org/", "https://golang.org/cmd/", 2},
+		{"https://golang.org/pkg/", "https://golang.org/cmd/", 1},
+	}
+	if !reflect.DeepEqual(stats.BrokenLinks, wantBroken) {
+		t.Errorf("BrokenLinks = %v, want %v", stats.BrokenLinks, wantBroken)
+	}
+}
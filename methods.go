@@ -7,18 +7,17 @@ import (
 	"strings"
 )
 
-// Vertex - vertex
-type Vertex struct {
-	X, Y float64
-}
+// Vertex - vertex. It is the float64 flavor of Point, see point.go.
+type Vertex = Point[float64]
 
 // There are no classes in Go. However, you can attach functions to Types.
-// This attaches abs() function to the Vertex type. The *receiver* is of
-// type Vertex named v.
+// This attaches abs() function to the Point type (and so to Vertex). The
+// *receiver* is of type Point[T] named v.
 // You can also write like normal and pass v in.
 // func abs(v Vertex) float64 {...}.
-func (v Vertex) abs() float64 {
-	return math.Sqrt(v.X*v.X + v.Y*v.Y)
+func (v Point[T]) abs() float64 {
+	x, y := float64(v.X), float64(v.Y)
+	return math.Sqrt(x*x + y*y)
 }
 
 // Declare an alias for a new type.
@@ -37,7 +36,7 @@ func (cf customFloat) abs() customFloat {
 // which has receives of "v Vertex" recieves a COPY (pass by value) of v.
 // Pointer receivers are more common than value ones because usually
 // you want to modify the receiver.
-func (v *Vertex) scale(factor float64) {
+func (v *Point[T]) scale(factor T) {
 	v.X = v.X * factor
 	v.Y = v.Y * factor
 }
@@ -268,7 +267,10 @@ func readers() {
 // MethodsMain is the entry point for Methods.
 func MethodsMain() {
 	methods()
+	vectors()
+	points()
 	interfaces()
+	shapes()
 	moreInterfaces()
 	errors()
 	readers()
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// cancelCheckEvery is how many items a worker reduces between looks at the
+// context, so a cancelled reduce stops soon without checking on every item.
+const cancelCheckEvery = 1024
+
+// ParallelReduce is the sum() split from ConcurrencyMain made general. It
+// splits items into up to chunks pieces, reduces each piece on a pool of at
+// most workers goroutines, then combines the piece results in order.
+//
+// Each piece starts from identity, so identity must not change a result
+// (0 for +, 1 for *), and combine must be associative. It does not need to be
+// commutative, the pieces are combined left to right.
+//
+// If ctx is cancelled first, it returns ctx.Err() and the zero R.
+func ParallelReduce[T, R any](ctx context.Context, items []T, chunks, workers int,
+	identity R, reduce func(R, T) R, combine func(R, R) R) (R, error) {
+	var zero R
+	if len(items) == 0 {
+		if err := ctx.Err(); err != nil {
+			return zero, err
+		}
+		return identity, nil
+	}
+	if chunks < 1 {
+		chunks = 1
+	}
+	if chunks > len(items) {
+		chunks = len(items)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Piece i is items[bounds[i]:bounds[i+1]].
+	bounds := make([]int, chunks+1)
+	for i := range bounds {
+		bounds[i] = i * len(items) / chunks
+	}
+	results := make([]R, chunks)
+
+	// Workers take piece numbers from a channel until it is closed.
+	pieces := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pieces {
+				acc := identity
+				for n, item := range items[bounds[i]:bounds[i+1]] {
+					if n%cancelCheckEvery == 0 && ctx.Err() != nil {
+						break
+					}
+					acc = reduce(acc, item)
+				}
+				// Each worker writes its own slots, so no lock is needed.
+				results[i] = acc
+			}
+		}()
+	}
+feed:
+	for i := 0; i < chunks; i++ {
+		select {
+		case pieces <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(pieces)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	total := identity
+	for _, result := range results {
+		total = combine(total, result)
+	}
+	return total, nil
+}
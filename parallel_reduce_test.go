@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParallelReduceSum(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 1000, 5000} {
+		items := make([]int, n)
+		want := 0
+		for i := range items {
+			items[i] = i - n/2
+			want += items[i]
+		}
+		for _, chunks := range []int{-1, 0, 1, 3, 64, n + 5} {
+			for _, workers := range []int{0, 1, 4} {
+				got, err := ParallelReduce(context.Background(), items, chunks, workers, 0, add, add)
+				if err != nil || got != want {
+					t.Errorf("n=%d chunks=%d workers=%d: got %d, %v, want %d", n, chunks, workers, got, err, want)
+				}
+			}
+		}
+	}
+}
+
+func TestParallelReduceKeepsOrder(t *testing.T) {
+	// Concatenation is associative but not commutative, so any mixup of the
+	// pieces shows.
+	words := strings.Fields("the quick brown fox jumps over the lazy dog")
+	concat := func(acc, word string) string { return acc + word + " " }
+	join := func(a, b string) string { return a + b }
+	for chunks := 1; chunks <= len(words); chunks++ {
+		got, err := ParallelReduce(context.Background(), words, chunks, 3, "", concat, join)
+		if want := strings.Join(words, " ") + " "; err != nil || got != want {
+			t.Errorf("chunks=%d: got %q, %v, want %q", chunks, got, err, want)
+		}
+	}
+}
+
+func TestParallelReduceCancelled(t *testing.T) {
+	items := make([]int, 1<<20)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got, err := ParallelReduce(ctx, items, 8, 4, 0, add, add); err != context.Canceled || got != 0 {
+		t.Errorf("already cancelled: got %d, %v, want 0, %v", got, err, context.Canceled)
+	}
+
+	// Cancel part way: every worker stops within cancelCheckEvery items,
+	// and none is left behind.
+	err := Watch("ParallelReduce", 5*time.Second, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reduced := 0
+		slowAdd := func(acc, item int) int {
+			if reduced++; reduced == 1000 {
+				cancel()
+			}
+			return acc + item
+		}
+		// One worker, so reduced needs no lock.
+		got, err := ParallelReduce(ctx, items, 16, 1, 0, slowAdd, add)
+		if err != context.Canceled || got != 0 {
+			t.Errorf("cancelled part way: got %d, %v, want 0, %v", got, err, context.Canceled)
+		}
+		if reduced > 1000+cancelCheckEvery {
+			t.Errorf("reduced %d items after cancelling at 1000", reduced)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// benchItems is what the sum benchmarks add up.
+var benchItems = func() []int {
+	items := make([]int, 1<<22)
+	for i := range items {
+		items[i] = i % 1000
+	}
+	return items
+}()
+
+func BenchmarkSumSequential(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		total := 0
+		for _, item := range benchItems {
+			total = add(total, item)
+		}
+		if total == 0 {
+			b.Fatal("empty sum")
+		}
+	}
+}
+
+// BenchmarkSumParallelReduce sums the same items as BenchmarkSumSequential.
+// Workers beyond GOMAXPROCS can't run at the same time, so only add overhead.
+func BenchmarkSumParallelReduce(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				total, err := ParallelReduce(context.Background(), benchItems, 4*workers, workers, 0, add, add)
+				if err != nil || total == 0 {
+					b.Fatal(total, err)
+				}
+			}
+		})
+	}
+}
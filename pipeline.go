@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Pipeline stages, the genValues/range pattern made reusable. Every stage
+// starts a goroutine that reads its input channel until it is closed, writes
+// to an output channel, and closes the output when done. So a consumer can
+// always `for v := range out`, like with genValues.
+//
+// Every stage also stops as soon as ctx is cancelled. That matters once a
+// consumer stops reading early (e.g. after Take): the stages before it would
+// block on send forever, and leak. Cancel the context when done with a
+// pipeline to release them.
+
+// send writes v to out, unless ctx is cancelled first. It reports whether v
+// was sent.
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// recv reads the next value from in. It reports false once in is closed or
+// ctx is cancelled, so a stage stops even if its input never closes.
+func recv[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// Generate emits values in order.
+func Generate[T any](ctx context.Context, values ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, v := range values {
+			if !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Map emits f(v) for every v from in, in the same order.
+func Map[T, U any](ctx context.Context, in <-chan T, f func(T) U) <-chan U {
+	out := make(chan U)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				break
+			}
+			if !send(ctx, out, f(v)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Filter emits the values from in for which keep is true, in the same order.
+func Filter[T any](ctx context.Context, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				break
+			}
+			if keep(v) && !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Batch groups the values from in into slices of size. The last slice is
+// shorter if in runs out in the middle of one.
+func Batch[T any](ctx context.Context, in <-chan T, size int) <-chan []T {
+	if size < 1 {
+		size = 1
+	}
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		batch := make([]T, 0, size)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				break
+			}
+			batch = append(batch, v)
+			if len(batch) == size {
+				if !send(ctx, out, batch) {
+					return
+				}
+				// The old batch belongs to the receiver now.
+				batch = make([]T, 0, size)
+			}
+		}
+		if len(batch) > 0 {
+			send(ctx, out, batch)
+		}
+	}()
+	return out
+}
+
+// Take emits the first n values from in, then closes its output. It stops
+// reading in at that point, so cancel ctx to release the stages before it.
+func Take[T any](ctx context.Context, in <-chan T, n int) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			v, ok := recv(ctx, in)
+			if !ok || !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// FanOut spreads the values from in over n outputs, each value going to
+// whichever output is read first. Use it to share work among n workers.
+// Order is only kept within each output.
+func FanOut[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		go func() {
+			defer close(out)
+			// Each output is its own reader of in, so the fastest one wins.
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					break
+				}
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+	return outs
+}
+
+// FanIn merges ins into one output, which is closed once all of them are.
+// Order is only kept among values from the same input.
+func FanIn[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Add(1)
+		go func(in <-chan T) {
+			defer wg.Done()
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					break
+				}
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}(in)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Tee copies every value from in to both outputs, in order. A value is only
+// read from in once both outputs took the previous one, so the slower
+// reader sets the pace.
+func Tee[T any](ctx context.Context, in <-chan T) (<-chan T, <-chan T) {
+	out1, out2 := make(chan T), make(chan T)
+	go func() {
+		defer close(out1)
+		defer close(out2)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				break
+			}
+			// Send to whichever is ready first, then to the other. Setting a
+			// channel to nil disables its select case.
+			o1, o2 := out1, out2
+			for o1 != nil || o2 != nil {
+				select {
+				case o1 <- v:
+					o1 = nil
+				case o2 <- v:
+					o2 = nil
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out1, out2
+}
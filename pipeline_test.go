@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collect reads ch until it is closed.
+func collect[T any](ch <-chan T) []T {
+	var values []T
+	for v := range ch {
+		values = append(values, v)
+	}
+	return values
+}
+
+// intsUpTo returns 0, 1, ..., n-1.
+func intsUpTo(n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = i
+	}
+	return values
+}
+
+func TestPipelineKeepsOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	odds := Filter(ctx, Generate(ctx, intsUpTo(20)...), func(n int) bool { return n%2 == 1 })
+	squares := Map(ctx, odds, func(n int) int { return n * n })
+	got := collect(Batch(ctx, squares, 3))
+	want := [][]int{{1, 9, 25}, {49, 81, 121}, {169, 225, 289}, {361}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := collect(Take(ctx, Generate(ctx, intsUpTo(10)...), 4)); !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+		t.Errorf("Take(4) = %v", got)
+	}
+	// Taking more than there is takes everything.
+	if got := collect(Take(ctx, Generate(ctx, 1, 2), 4)); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("Take(4) of 2 = %v", got)
+	}
+}
+
+func TestTeeKeepsOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out1, out2 := Tee(ctx, Generate(ctx, intsUpTo(100)...))
+	var got1 []int
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		got1 = collect(out1)
+	}()
+	got2 := collect(out2)
+	wg.Wait()
+	if !reflect.DeepEqual(got1, intsUpTo(100)) || !reflect.DeepEqual(got2, intsUpTo(100)) {
+		t.Errorf("Tee outputs %v and %v, want 0..99 in both", got1, got2)
+	}
+}
+
+func TestFanOutFanIn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	outs := FanOut(ctx, Generate(ctx, intsUpTo(1000)...), 4)
+	// Each output keeps the order of in, and together they have every value
+	// exactly once.
+	var mux sync.Mutex
+	var all []int
+	var wg sync.WaitGroup
+	for i, out := range outs {
+		wg.Add(1)
+		go func(i int, out <-chan int) {
+			defer wg.Done()
+			values := collect(out)
+			if !sort.IntsAreSorted(values) {
+				t.Errorf("output %d out of order: %v", i, values)
+			}
+			mux.Lock()
+			all = append(all, values...)
+			mux.Unlock()
+		}(i, out)
+	}
+	wg.Wait()
+	sort.Ints(all)
+	if !reflect.DeepEqual(all, intsUpTo(1000)) {
+		t.Errorf("FanOut lost or duplicated values: got %d of 1000", len(all))
+	}
+
+	// FanIn keeps the order of each input. Tag values with their input.
+	evens := Map(ctx, Generate(ctx, intsUpTo(500)...), func(n int) int { return 2 * n })
+	odds := Map(ctx, Generate(ctx, intsUpTo(500)...), func(n int) int { return 2*n + 1 })
+	last := map[int]int{0: -2, 1: -1}
+	merged := 0
+	for v := range FanIn(ctx, evens, odds) {
+		if v <= last[v%2] {
+			t.Fatalf("%d came after %d", v, last[v%2])
+		}
+		last[v%2] = v
+		merged++
+	}
+	if merged != 1000 {
+		t.Errorf("FanIn emitted %d values, want 1000", merged)
+	}
+}
+
+// TestPipelineNoLeaks stops reading every stage early, cancels, and checks
+// with Watch that no stage goroutine is left behind.
+func TestPipelineNoLeaks(t *testing.T) {
+	// forever has far more values than a test reads, so stages reading it only
+	// stop on cancel.
+	forever := func(ctx context.Context) <-chan int {
+		return Generate(ctx, intsUpTo(1<<20)...)
+	}
+	stages := map[string]func(ctx context.Context) <-chan int{
+		"Generate": forever,
+		"Map": func(ctx context.Context) <-chan int {
+			return Map(ctx, forever(ctx), func(n int) int { return n + 1 })
+		},
+		"Filter": func(ctx context.Context) <-chan int {
+			return Filter(ctx, forever(ctx), func(n int) bool { return n%3 == 0 })
+		},
+		"Batch": func(ctx context.Context) <-chan int {
+			return Map(ctx, Batch(ctx, forever(ctx), 4), func(b []int) int { return b[0] })
+		},
+		"Take": func(ctx context.Context) <-chan int {
+			return Take(ctx, forever(ctx), 1000)
+		},
+		"FanOut and FanIn": func(ctx context.Context) <-chan int {
+			return FanIn(ctx, FanOut(ctx, forever(ctx), 3)...)
+		},
+		"Tee": func(ctx context.Context) <-chan int {
+			out1, out2 := Tee(ctx, forever(ctx))
+			return FanIn(ctx, out1, out2)
+		},
+	}
+	for name, stage := range stages {
+		err := Watch(name, 5*time.Second, func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			out := stage(ctx)
+			for i := 0; i < 10; i++ {
+				<-out
+			}
+			cancel()
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	// Cancelling also stops a stage whose input never sends at all.
+	err := Watch("stuck input", 5*time.Second, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Map(ctx, make(chan int), func(n int) int { return n })
+		cancel()
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
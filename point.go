@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Number is the coordinate types a Point can have.
+type Number interface {
+	~int | ~float64
+}
+
+// Point is a 2D point. The Types lesson's vertex is a Point[int] and the
+// Methods lesson's Vertex a Point[float64], so both share the methods here,
+// in methods.go (abs, scale) and in vertex.go.
+//
+// It encodes to JSON as {"X": 1, "Y": 2}, the field names Vertex always
+// had. Decoding also takes "x" and "y", since encoding/json matches field
+// names without regard to case.
+type Point[T Number] struct {
+	X, Y T
+}
+
+// Float returns p with float64 coordinates, e.g. to turn a vertex into a
+// Vertex.
+func (p Point[T]) Float() Point[float64] {
+	return Point[float64]{float64(p.X), float64(p.Y)}
+}
+
+// Round returns p with each coordinate rounded to the nearest int, e.g. to
+// turn a Vertex into a vertex. Halves round away from zero, so -2.5 becomes
+// -3, like math.Round.
+func (p Point[T]) Round() Point[int] {
+	return Point[int]{int(math.Round(float64(p.X))), int(math.Round(float64(p.Y)))}
+}
+
+func points() {
+	// The Types lesson's vertex and the Methods lesson's Vertex are now the
+	// same type with different coordinates, so they share methods.
+	v := vertex{3, 4}
+	f := Vertex{1.5, 2}
+	fmt.Println(v.abs(), f.abs())
+	fmt.Println(v.Add(vertex{1, 1}), f.Add(v.Float()), f.Round())
+	fmt.Println(v == vertex{3, 4}, f.Equal(Vertex{1.5, 2.0000001}, 1e-6))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPointJSON(t *testing.T) {
+	v := vertex{3, -4}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) != `{"X":3,"Y":-4}` {
+		t.Errorf("Marshal(%v) = %s, %v", v, data, err)
+	}
+	var v2 vertex
+	if err := json.Unmarshal(data, &v2); err != nil || v2 != v {
+		t.Errorf("round trip of %v gave %v, %v", v, v2, err)
+	}
+
+	f := Vertex{1.5, -0.25}
+	data, err = json.Marshal(f)
+	if err != nil || string(data) != `{"X":1.5,"Y":-0.25}` {
+		t.Errorf("Marshal(%v) = %s, %v", f, data, err)
+	}
+	var f2 Vertex
+	if err := json.Unmarshal(data, &f2); err != nil || f2 != f {
+		t.Errorf("round trip of %v gave %v, %v", f, f2, err)
+	}
+
+	// Lowercase names decode too.
+	var lower Vertex
+	if err := json.Unmarshal([]byte(`{"x":1,"y":2}`), &lower); err != nil || lower != (Vertex{1, 2}) {
+		t.Errorf(`Unmarshal of {"x":1,"y":2} = %v, %v`, lower, err)
+	}
+	// A vertex has int coordinates, so a fraction doesn't fit.
+	if err := json.Unmarshal([]byte(`{"X":1.5,"Y":2}`), &v2); err == nil {
+		t.Errorf("Unmarshal of a fraction into a vertex gave %v", v2)
+	}
+}
+
+func TestPointConversions(t *testing.T) {
+	if got := (vertex{3, -4}).Float(); got != (Vertex{3, -4}) {
+		t.Errorf("Float() = %v", got)
+	}
+	tests := []struct {
+		in   Vertex
+		want vertex
+	}{
+		{Vertex{0, 0}, vertex{0, 0}},
+		{Vertex{1.4, -1.4}, vertex{1, -1}},
+		{Vertex{1.6, -1.6}, vertex{2, -2}},
+		// Halves round away from zero.
+		{Vertex{2.5, -2.5}, vertex{3, -3}},
+		{Vertex{0.5, -0.5}, vertex{1, -1}},
+		{Vertex{-0.49, 0.49}, vertex{0, 0}},
+	}
+	for _, test := range tests {
+		if got := test.in.Round(); got != test.want {
+			t.Errorf("%v.Round() = %v, want %v", test.in, got, test.want)
+		}
+		// Whole numbers survive the trip there and back.
+		if back := test.want.Float().Round(); back != test.want {
+			t.Errorf("%v.Float().Round() = %v", test.want, back)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FetchRecord is one recorded call to Fetch.
+type FetchRecord struct {
+	Body  string   `json:"body"`
+	URLs  []string `json:"urls"`
+	Error string   `json:"error,omitempty"`
+	// Duration is how long the real fetch took, in nanoseconds.
+	Duration time.Duration `json:"duration"`
+}
+
+// FetchArchive maps each fetched URL to what Fetch returned for it.
+type FetchArchive map[string]FetchRecord
+
+// RecordingFetcher wraps another Fetcher and remembers every result, so a
+// crawl can be saved with Save and replayed later by a ReplayFetcher.
+type RecordingFetcher struct {
+	Fetcher Fetcher
+
+	mux     sync.Mutex
+	archive FetchArchive
+}
+
+// NewRecordingFetcher records the results of fetcher.
+func NewRecordingFetcher(fetcher Fetcher) *RecordingFetcher {
+	return &RecordingFetcher{Fetcher: fetcher, archive: make(FetchArchive)}
+}
+
+// Fetch calls the wrapped Fetcher and records the result.
+func (r *RecordingFetcher) Fetch(url string) (string, []string, error) {
+	start := time.Now()
+	body, urls, err := r.Fetcher.Fetch(url)
+	record := FetchRecord{Body: body, URLs: urls, Duration: time.Since(start)}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.archive[url] = record
+	return body, urls, err
+}
+
+// Archive returns a copy of everything recorded so far.
+func (r *RecordingFetcher) Archive() FetchArchive {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	archive := make(FetchArchive, len(r.archive))
+	for url, record := range r.archive {
+		archive[url] = record
+	}
+	return archive
+}
+
+// Write writes the archive as JSON.
+func (archive FetchArchive) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	// Map keys are sorted by encoding/json, so archives diff nicely.
+	enc.SetIndent("", "  ")
+	return enc.Encode(archive)
+}
+
+// Save writes the recorded archive to the file at path.
+func (r *RecordingFetcher) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.Archive().Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReplayFetcher serves a recorded FetchArchive, without touching the network.
+// URLs that were never recorded fail, and are remembered, see Missing.
+type ReplayFetcher struct {
+	archive FetchArchive
+
+	mux     sync.Mutex
+	missing map[string]bool
+}
+
+// NewReplayFetcher replays archive.
+func NewReplayFetcher(archive FetchArchive) *ReplayFetcher {
+	return &ReplayFetcher{archive: archive, missing: make(map[string]bool)}
+}
+
+// LoadReplayFetcher replays the archive saved at path by RecordingFetcher.Save.
+func LoadReplayFetcher(path string) (*ReplayFetcher, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var archive FetchArchive
+	if err := json.NewDecoder(file).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return NewReplayFetcher(archive), nil
+}
+
+// Fetch returns what was recorded for url, including a recorded error.
+func (r *ReplayFetcher) Fetch(url string) (string, []string, error) {
+	record, ok := r.archive[url]
+	if !ok {
+		r.mux.Lock()
+		r.missing[url] = true
+		r.mux.Unlock()
+		return "", nil, fmt.Errorf("not recorded: %s", url)
+	}
+	if record.Error != "" {
+		// The package has its own errors() demo, so no errors.New here.
+		return "", nil, fmt.Errorf("%s", record.Error)
+	}
+	return record.Body, record.URLs, nil
+}
+
+// Missing returns the sorted URLs that were fetched but never recorded.
+// An empty result means the replay reproduced the recorded crawl.
+func (r *ReplayFetcher) Missing() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	missing := make([]string, 0, len(r.missing))
+	for url := range r.missing {
+		missing = append(missing, url)
+	}
+	sort.Strings(missing)
+	return missing
+}
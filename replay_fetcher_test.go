@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recordSite crawls a local HTTP server to depth while recording it, saves
+// the recording, and returns the cached pages and the saved archive's path.
+// The server is shut down before it returns.
+func recordSite(t *testing.T, depth int) (string, map[string]string, string) {
+	t.Helper()
+	pages := map[string]string{
+		"/":         `Home <a href="/pkg/">pkg</a> <a href="/cmd/">cmd</a>`,
+		"/pkg/":     `Packages <a href="/">home</a> <a href="fmt/">fmt</a>`,
+		"/pkg/fmt/": `Package fmt <a href="../">pkg</a>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	recorder := NewRecordingFetcher(HTTPFetcher{Client: server.Client()})
+	cache := &SafeCache{}
+	crawl(server.URL+"/", depth, recorder, cache)
+	path := filepath.Join(t.TempDir(), "crawl.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL, cachedPages(cache, recorder.Archive()), path
+}
+
+// cachedPages returns the body cached for each URL in archive.
+func cachedPages(cache *SafeCache, archive FetchArchive) map[string]string {
+	bodies := make(map[string]string)
+	for url := range archive {
+		if body, ok := cache.Get(url); ok {
+			bodies[url] = body
+		}
+	}
+	return bodies
+}
+
+func TestReplayReproducesRecordedCrawl(t *testing.T) {
+	seed, recorded, path := recordSite(t, 3)
+	if len(recorded) != 3 {
+		t.Fatalf("recorded crawl cached %d pages, want 3: %v", len(recorded), recorded)
+	}
+	replay, err := LoadReplayFetcher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The server is gone, so this can only be served from the archive.
+	cache := &SafeCache{}
+	crawl(seed+"/", 3, replay, cache)
+	if missing := replay.Missing(); len(missing) != 0 {
+		t.Errorf("Missing() = %v, want none", missing)
+	}
+	if replayed := cachedPages(cache, replay.archive); !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replay cached %v, want %v", replayed, recorded)
+	}
+}
+
+func TestReplayReportsUnrecordedURLs(t *testing.T) {
+	// At depth 2 the links on /pkg/ are never fetched, so a deeper replay
+	// asks for /pkg/fmt/, which was never recorded.
+	seed, _, path := recordSite(t, 2)
+	replay, err := LoadReplayFetcher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawl(seed+"/", 3, replay, &SafeCache{})
+	if want := []string{seed + "/pkg/fmt/"}; !reflect.DeepEqual(replay.Missing(), want) {
+		t.Errorf("Missing() = %v, want %v", replay.Missing(), want)
+	}
+}
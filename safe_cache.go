@@ -0,0 +1,204 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Cache maps urls to bodies. SafeCache, ShardedSafeCache and DiskCache all
+// implement it, so crawl works with any of them.
+type Cache interface {
+	Add(url string, body string)
+	Get(url string) (body string, ok bool)
+}
+
+// EvictionPolicy picks which entry a full SafeCache drops to make room.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used entry.
+	LRU EvictionPolicy = iota
+	// LFU evicts the least frequently used entry. Ties go to the least
+	// recently used one.
+	LFU
+)
+
+// CacheStats counts what happened to a SafeCache.
+type CacheStats struct {
+	Hits, Misses int
+	// Evictions are entries dropped to stay under Capacity.
+	Evictions int
+	// Expirations are entries dropped because they outlived TTL.
+	Expirations int
+}
+
+// cacheEntry is one url in the cache. Entries sit in a heap ordered by the
+// eviction policy, so finding the victim is cheap.
+type cacheEntry struct {
+	url     string
+	body    string
+	expires time.Time
+	// uses counts Adds and Gets, lastUsed is the tick of the latest one.
+	uses, lastUsed int
+	// index is the position in the heap, maintained by entryHeap.
+	index int
+}
+
+// SafeCache is a map of url to HTTP body, safe for concurrent use.
+// The zero value is an unbounded cache that never expires entries.
+type SafeCache struct {
+	// Capacity bounds the number of entries. 0 means no limit.
+	Capacity int
+	// Policy decides which entry to evict once Capacity is reached. Like the
+	// other options, set it before the first Add.
+	Policy EvictionPolicy
+	// TTL is how long an entry lives after it is added. 0 means forever.
+	TTL time.Duration
+	// Clock tells the time, nil means real time. Swap in a fake clock to
+	// test expiry without sleeping.
+	Clock Clock
+
+	// Map of url to body.
+	cache map[string]*cacheEntry
+	order entryHeap
+	tick  int
+	stats CacheStats
+	mux   sync.Mutex
+}
+
+func (safeCache *SafeCache) now() time.Time {
+	return orRealClock(safeCache.Clock).Now()
+}
+
+// touch marks entry as used right now, and fixes its place in the heap.
+func (safeCache *SafeCache) touch(entry *cacheEntry) {
+	safeCache.tick++
+	entry.uses++
+	entry.lastUsed = safeCache.tick
+	heap.Fix(&safeCache.order, entry.index)
+}
+
+func (safeCache *SafeCache) remove(entry *cacheEntry) {
+	heap.Remove(&safeCache.order, entry.index)
+	delete(safeCache.cache, entry.url)
+}
+
+func (entry *cacheEntry) expired(now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
+}
+
+// dropExpired removes every expired entry. It is only worth the scan when the
+// cache is full, so expired entries make room before live ones are evicted.
+func (safeCache *SafeCache) dropExpired() {
+	if safeCache.TTL <= 0 {
+		return
+	}
+	now := safeCache.now()
+	for _, entry := range safeCache.cache {
+		if entry.expired(now) {
+			safeCache.remove(entry)
+			safeCache.stats.Expirations++
+		}
+	}
+}
+
+// Add safely to cache.
+func (safeCache *SafeCache) Add(url string, body string) {
+	safeCache.mux.Lock()
+	defer safeCache.mux.Unlock()
+	if safeCache.cache == nil {
+		safeCache.cache = make(map[string]*cacheEntry)
+		safeCache.order.policy = safeCache.Policy
+	}
+	var expires time.Time
+	if safeCache.TTL > 0 {
+		expires = safeCache.now().Add(safeCache.TTL)
+	}
+	if entry, ok := safeCache.cache[url]; ok {
+		entry.body, entry.expires = body, expires
+		safeCache.touch(entry)
+		return
+	}
+	if safeCache.Capacity > 0 && len(safeCache.cache) >= safeCache.Capacity {
+		safeCache.dropExpired()
+	}
+	if safeCache.Capacity > 0 && len(safeCache.cache) >= safeCache.Capacity {
+		safeCache.remove(safeCache.order.entries[0])
+		safeCache.stats.Evictions++
+	}
+	entry := &cacheEntry{url: url, body: body, expires: expires}
+	safeCache.cache[url] = entry
+	heap.Push(&safeCache.order, entry)
+	safeCache.touch(entry)
+}
+
+// Get from cache.
+func (safeCache *SafeCache) Get(url string) (body string, ok bool) {
+	safeCache.mux.Lock()
+	defer safeCache.mux.Unlock()
+	entry, ok := safeCache.cache[url]
+	if ok && entry.expired(safeCache.now()) {
+		// Expired entries are only dropped when somebody looks at them, or
+		// when the cache is full.
+		safeCache.remove(entry)
+		safeCache.stats.Expirations++
+		ok = false
+	}
+	if !ok {
+		safeCache.stats.Misses++
+		return "", false
+	}
+	safeCache.stats.Hits++
+	safeCache.touch(entry)
+	return entry.body, true
+}
+
+// Len returns the number of entries, including expired ones not yet dropped.
+func (safeCache *SafeCache) Len() int {
+	safeCache.mux.Lock()
+	defer safeCache.mux.Unlock()
+	return len(safeCache.cache)
+}
+
+// Stats returns the hit, miss and eviction counters.
+func (safeCache *SafeCache) Stats() CacheStats {
+	safeCache.mux.Lock()
+	defer safeCache.mux.Unlock()
+	return safeCache.stats
+}
+
+// entryHeap implements heap.Interface. The root is the next entry to evict.
+type entryHeap struct {
+	entries []*cacheEntry
+	policy  EvictionPolicy
+}
+
+func (h entryHeap) Len() int { return len(h.entries) }
+
+func (h entryHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if h.policy == LFU && a.uses != b.uses {
+		return a.uses < b.uses
+	}
+	return a.lastUsed < b.lastUsed
+}
+
+func (h entryHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *entryHeap) Push(x interface{}) {
+	entry := x.(*cacheEntry)
+	entry.index = len(h.entries)
+	h.entries = append(h.entries, entry)
+}
+
+func (h *entryHeap) Pop() interface{} {
+	last := h.entries[len(h.entries)-1]
+	h.entries[len(h.entries)-1] = nil
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
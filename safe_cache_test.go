@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// has reports which of urls are in cache, e.g. "a- b+" when only b is.
+func has(cache *SafeCache, urls ...string) string {
+	s := ""
+	for i, url := range urls {
+		if i > 0 {
+			s += " "
+		}
+		if _, ok := cache.Get(url); ok {
+			s += url + "+"
+		} else {
+			s += url + "-"
+		}
+	}
+	return s
+}
+
+func TestSafeCacheExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := &SafeCache{TTL: 10 * time.Second, Clock: clock}
+	cache.Add("a", "A")
+	clock.Advance(9 * time.Second)
+	if body, ok := cache.Get("a"); !ok || body != "A" {
+		t.Fatalf("Get before TTL = %q, %v", body, ok)
+	}
+	// Adding again starts the TTL over.
+	cache.Add("a", "A2")
+	clock.Advance(9 * time.Second)
+	if body, ok := cache.Get("a"); !ok || body != "A2" {
+		t.Fatalf("Get after re-Add = %q, %v", body, ok)
+	}
+	// The entry expires exactly TTL after it was added.
+	clock.Advance(time.Second)
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("Get after TTL hit")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d after expiry, want 0", cache.Len())
+	}
+	want := CacheStats{Hits: 2, Misses: 1, Expirations: 1}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSafeCacheFullDropsExpiredFirst(t *testing.T) {
+	clock := newFakeClock()
+	cache := &SafeCache{Capacity: 2, TTL: 10 * time.Second, Clock: clock}
+	cache.Add("a", "A")
+	clock.Advance(5 * time.Second)
+	cache.Add("b", "B")
+	// b is the least recently used entry, but a has expired.
+	cache.Get("a")
+	clock.Advance(6 * time.Second)
+	cache.Add("c", "C")
+	if got := has(cache, "b", "c"); got != "b+ c+" {
+		t.Errorf("after Add(c): %s", got)
+	}
+	want := CacheStats{Hits: 3, Expirations: 1}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSafeCacheEviction(t *testing.T) {
+	tests := []struct {
+		policy EvictionPolicy
+		// ops are "+url" to Add and "url" to Get, in order.
+		ops  []string
+		want string
+	}{
+		// b is the least recently used when c comes in.
+		{LRU, []string{"+a", "+b", "a", "+c"}, "a+ b- c+"},
+		{LRU, []string{"+a", "+b", "+c"}, "a- b+ c+"},
+		// Adding again counts as a use.
+		{LRU, []string{"+a", "+b", "+a", "+c"}, "a+ b- c+"},
+		// a was used three times, b once.
+		{LFU, []string{"+a", "a", "a", "+b", "+c"}, "a+ b- c+"},
+		// b was used more recently, but a more often.
+		{LFU, []string{"+a", "a", "+b", "b", "a", "+c"}, "a+ b- c+"},
+		// Equal uses fall back to LRU.
+		{LFU, []string{"+a", "+b", "a", "b", "+c"}, "a- b+ c+"},
+	}
+	for _, test := range tests {
+		cache := &SafeCache{Capacity: 2, Policy: test.policy}
+		for _, op := range test.ops {
+			if op[0] == '+' {
+				cache.Add(op[1:], "body")
+			} else {
+				cache.Get(op)
+			}
+		}
+		// Check evictions before has() changes the use counts.
+		if got := cache.Stats().Evictions; got != 1 {
+			t.Errorf("policy %d, %v: %d evictions, want 1", test.policy, test.ops, got)
+		}
+		if got := has(cache, "a", "b", "c"); got != test.want {
+			t.Errorf("policy %d, %v: %s, want %s", test.policy, test.ops, got, test.want)
+		}
+	}
+}
+
+func TestSafeCacheConcurrent(t *testing.T) {
+	// The zero value works, and stays consistent under concurrent use. Run
+	// with -race.
+	cache := &SafeCache{}
+	bounded := &SafeCache{Capacity: 16, Policy: LFU, TTL: time.Hour}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				url := fmt.Sprint(i % 50)
+				cache.Add(url, url)
+				bounded.Add(url, url)
+				if body, ok := cache.Get(url); !ok || body != url {
+					t.Errorf("Get(%q) = %q, %v", url, body, ok)
+				}
+				bounded.Get(fmt.Sprint((i + g) % 50))
+			}
+		}(g)
+	}
+	wg.Wait()
+	if cache.Len() != 50 {
+		t.Errorf("Len() = %d, want 50", cache.Len())
+	}
+	if bounded.Len() != 16 {
+		t.Errorf("bounded Len() = %d, want 16", bounded.Len())
+	}
+}
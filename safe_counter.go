@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The basic Inc and Value of SafeCounter live in concurrency.go, next to the
+// mutex lesson. These turn it into a small metrics registry.
+
+// Counter is the method set shared by SafeCounter, ShardedSafeCounter and
+// AtomicCounter, so any of them can be used wherever another is.
+type Counter interface {
+	Inc(key string)
+	Add(key string, n int)
+	Value(key string) int
+	Delete(key string)
+	Reset()
+	Snapshot() map[string]int
+	TopK(k int) []CounterEntry
+	WriteText(w io.Writer) error
+}
+
+// CounterEntry is one key of a SafeCounter and its value.
+type CounterEntry struct {
+	Key   string
+	Value int
+}
+
+// Add adds n to the counter for the given key. n may be negative.
+func (counter *SafeCounter) Add(key string, n int) {
+	counter.mux.Lock()
+	defer counter.mux.Unlock()
+	if counter.m == nil {
+		counter.m = make(map[string]int)
+	}
+	counter.m[key] += n
+}
+
+// Delete removes the given key.
+func (counter *SafeCounter) Delete(key string) {
+	counter.mux.Lock()
+	defer counter.mux.Unlock()
+	delete(counter.m, key)
+}
+
+// Reset removes every key.
+func (counter *SafeCounter) Reset() {
+	counter.mux.Lock()
+	defer counter.mux.Unlock()
+	counter.m = make(map[string]int)
+}
+
+// Snapshot returns a copy of every counter, taken under one lock, so the
+// values are consistent with each other.
+func (counter *SafeCounter) Snapshot() map[string]int {
+	counter.mux.Lock()
+	defer counter.mux.Unlock()
+	snapshot := make(map[string]int, len(counter.m))
+	for key, value := range counter.m {
+		snapshot[key] = value
+	}
+	return snapshot
+}
+
+// sortedEntries returns the snapshot as a slice, highest value first, and
+// by key among equal values.
+func sortedEntries(snapshot map[string]int) []CounterEntry {
+	entries := make([]CounterEntry, 0, len(snapshot))
+	for key, value := range snapshot {
+		entries = append(entries, CounterEntry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return entries[i].Value > entries[j].Value
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// TopK returns the k keys with the highest values, highest first. It returns
+// every key if there are fewer than k, and none if k <= 0.
+func (counter *SafeCounter) TopK(k int) []CounterEntry {
+	return topK(counter.Snapshot(), k)
+}
+
+func topK(snapshot map[string]int, k int) []CounterEntry {
+	entries := sortedEntries(snapshot)
+	if k < 0 {
+		k = 0
+	}
+	if k < len(entries) {
+		entries = entries[:k]
+	}
+	return entries
+}
+
+// WriteText writes one "key value" line per key, sorted by key, so the
+// output of two runs can be compared with diff.
+func (counter *SafeCounter) WriteText(w io.Writer) error {
+	return writeCounterText(w, counter.Snapshot())
+}
+
+func writeCounterText(w io.Writer, snapshot map[string]int) error {
+	entries := sortedEntries(snapshot)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	for _, entry := range entries {
+		if _, err := fmt.Fprintf(w, "%s %d\n", entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// counterTypes are the Counter implementations every test here runs on.
+var counterTypes = []struct {
+	name string
+	new  func() Counter
+}{
+	{"SafeCounter", func() Counter { return &SafeCounter{} }},
+	{"ShardedSafeCounter", func() Counter { return NewShardedSafeCounter(8) }},
+	{"AtomicCounter", func() Counter { return &AtomicCounter{} }},
+}
+
+func TestCounterMethods(t *testing.T) {
+	for _, counterType := range counterTypes {
+		t.Run(counterType.name, func(t *testing.T) {
+			counter := counterType.new()
+			counter.Inc("fetched")
+			counter.Add("fetched", 4)
+			counter.Add("error", 2)
+			counter.Add("filtered", 2)
+			counter.Add("cache_hit", -1)
+			if got := counter.Value("fetched"); got != 5 {
+				t.Errorf("Value = %d, want 5", got)
+			}
+			if got := counter.Value("unknown"); got != 0 {
+				t.Errorf("Value of an unknown key = %d, want 0", got)
+			}
+
+			var buf bytes.Buffer
+			if err := counter.WriteText(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if want := "cache_hit -1\nerror 2\nfetched 5\nfiltered 2\n"; buf.String() != want {
+				t.Errorf("WriteText =\n%s\nwant\n%s", buf.String(), want)
+			}
+
+			// Equal values are ordered by key.
+			all := []CounterEntry{{"fetched", 5}, {"error", 2}, {"filtered", 2}, {"cache_hit", -1}}
+			for _, test := range []struct {
+				k    int
+				want []CounterEntry
+			}{
+				{2, all[:2]},
+				{4, all},
+				{10, all},
+				{0, []CounterEntry{}},
+				{-1, []CounterEntry{}},
+			} {
+				if got := counter.TopK(test.k); !reflect.DeepEqual(got, test.want) {
+					t.Errorf("TopK(%d) = %v, want %v", test.k, got, test.want)
+				}
+			}
+
+			counter.Delete("fetched")
+			counter.Delete("unknown")
+			want := map[string]int{"error": 2, "filtered": 2, "cache_hit": -1}
+			if got := counter.Snapshot(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Snapshot after Delete = %v, want %v", got, want)
+			}
+			counter.Reset()
+			if got := counter.Snapshot(); len(got) != 0 {
+				t.Errorf("Snapshot after Reset = %v, want empty", got)
+			}
+			counter.Inc("fetched")
+			if got := counter.Value("fetched"); got != 1 {
+				t.Errorf("Value after Reset and Inc = %d, want 1", got)
+			}
+		})
+	}
+}
+
+// The hammer tests are meant for -race. They also check the totals, which a
+// lost update would get wrong.
+
+func TestCounterConcurrentAdd(t *testing.T) {
+	const goroutines, n = 16, 1000
+	for _, counterType := range counterTypes {
+		t.Run(counterType.name, func(t *testing.T) {
+			counter := counterType.new()
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < n; i++ {
+						counter.Inc("hot")
+						counter.Add(fmt.Sprint("key", i%10), 2)
+						counter.Add("down", -1)
+						counter.Add(fmt.Sprint("own", g), 1)
+					}
+				}(g)
+			}
+			wg.Wait()
+			want := map[string]int{"hot": goroutines * n, "down": -goroutines * n}
+			for i := 0; i < 10; i++ {
+				want[fmt.Sprint("key", i)] = goroutines * n / 10 * 2
+			}
+			for g := 0; g < goroutines; g++ {
+				want[fmt.Sprint("own", g)] = n
+			}
+			if got := counter.Snapshot(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Snapshot() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCounterConcurrentSnapshot(t *testing.T) {
+	for _, counterType := range counterTypes {
+		t.Run(counterType.name, func(t *testing.T) {
+			counter := counterType.new()
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			for g := 0; g < 4; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 2000; i++ {
+						counter.Inc(fmt.Sprint("key", i%5))
+					}
+				}()
+			}
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			// With only increments running, no key can ever go down between
+			// two snapshots.
+			last := map[string]int{}
+			for finished := false; !finished; {
+				select {
+				case <-done:
+					finished = true
+				default:
+				}
+				snapshot := counter.Snapshot()
+				for key, value := range last {
+					if snapshot[key] < value {
+						t.Fatalf("%s went from %d to %d", key, value, snapshot[key])
+					}
+				}
+				last = snapshot
+			}
+			for i := 0; i < 5; i++ {
+				if got := last[fmt.Sprint("key", i)]; got != 1600 {
+					t.Errorf("key%d = %d, want 1600", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCounterConcurrentDeleteReset(t *testing.T) {
+	for _, counterType := range counterTypes {
+		t.Run(counterType.name, func(t *testing.T) {
+			counter := counterType.new()
+			var wg sync.WaitGroup
+			for g := 0; g < 8; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 1000; i++ {
+						key := fmt.Sprint("key", i%7)
+						switch (g + i) % 5 {
+						case 0:
+							counter.Delete(key)
+						case 1:
+							if i%100 == 1 {
+								counter.Reset()
+							}
+						case 2:
+							counter.TopK(3)
+						default:
+							counter.Add(key, 1)
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+			// Whatever survived, the counter still works.
+			counter.Reset()
+			counter.Add("key0", 3)
+			if got := counter.Snapshot(); !reflect.DeepEqual(got, map[string]int{"key0": 3}) {
+				t.Errorf("Snapshot() = %v, want only key0", got)
+			}
+		})
+	}
+}
+
+// TestCounterResetIsAtomic increments a then b, over and over, while Reset
+// runs. A Reset that cleared a key at a time could land a's clear after
+// several b increments. Clearing both at once, b can only ever be ahead of a
+// by the one pair that Reset split.
+func TestCounterResetIsAtomic(t *testing.T) {
+	for _, counterType := range counterTypes {
+		t.Run(counterType.name, func(t *testing.T) {
+			counter := counterType.new()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 20000; i++ {
+					counter.Inc("a")
+					counter.Inc("b")
+				}
+			}()
+			for resetting := true; resetting; {
+				select {
+				case <-done:
+					resetting = false
+				default:
+					counter.Reset()
+				}
+			}
+			a, b := counter.Value("a"), counter.Value("b")
+			if b != a && b != a+1 {
+				t.Errorf("a = %d, b = %d after the last Reset, want b = a or a+1", a, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// The "Google Search" example that ends the concurrency talk:
+// https://talks.golang.org/2012/concurrency.slide#42
+// Each version asks the same fake backends, and only changes how it waits.
+
+// Result is what a search backend returns.
+type Result string
+
+// Search is one search backend.
+type Search func(query string) Result
+
+// FakeSearch is a backend of the given kind ("web", "image", ...) that
+// takes latency() to answer.
+func FakeSearch(kind string, latency func() time.Duration) Search {
+	return func(query string) Result {
+		time.Sleep(latency())
+		return Result(fmt.Sprintf("%s result for %q", kind, query))
+	}
+}
+
+// RandomLatency returns latencies evenly spread in [0, max), like the talk.
+func RandomLatency(max time.Duration) func() time.Duration {
+	return func() time.Duration {
+		return time.Duration(rand.Int63n(int64(max)))
+	}
+}
+
+// ErrNoReplicas is returned when there is no server to ask.
+var ErrNoReplicas = fmt.Errorf("no replicas")
+
+// ErrSearchTimeout is returned with the results that made it in time, when
+// some did not.
+var ErrSearchTimeout = fmt.Errorf("search timed out")
+
+// First asks every replica and returns the first answer. The channel has
+// room for every answer, so the slower replicas don't block forever.
+func First(query string, replicas ...Search) (Result, error) {
+	if len(replicas) == 0 {
+		// Nobody would ever answer.
+		return "", ErrNoReplicas
+	}
+	c := make(chan Result, len(replicas))
+	for _, replica := range replicas {
+		go func(replica Search) { c <- replica(query) }(replica)
+	}
+	return <-c, nil
+}
+
+// SearchEngine has one or more replicas of each backend. Versions before
+// 3.0 only use the first replica. Every version returns ErrNoReplicas if a
+// backend has none.
+type SearchEngine struct {
+	Web, Image, Video []Search
+	// Clock times out 2.1 and 3.0. nil means real time.
+	Clock Clock
+}
+
+// backends returns the replicas of each backend, web, image then video.
+func (engine SearchEngine) backends() ([][]Search, error) {
+	backends := [][]Search{engine.Web, engine.Image, engine.Video}
+	for i, replicas := range backends {
+		if len(replicas) == 0 {
+			return nil, fmt.Errorf("%w for %s", ErrNoReplicas, []string{"web", "image", "video"}[i])
+		}
+	}
+	return backends, nil
+}
+
+// firstReplicas returns the first replica of each backend.
+func (engine SearchEngine) firstReplicas() ([]Search, error) {
+	backends, err := engine.backends()
+	if err != nil {
+		return nil, err
+	}
+	first := make([]Search, len(backends))
+	for i, replicas := range backends {
+		first[i] = replicas[0]
+	}
+	return first, nil
+}
+
+// Search10 asks each backend in turn, so it takes as long as all of them
+// together.
+func (engine SearchEngine) Search10(query string) ([]Result, error) {
+	backends, err := engine.firstReplicas()
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, backend := range backends {
+		results = append(results, backend(query))
+	}
+	return results, nil
+}
+
+// Search20 asks the backends at the same time, so it takes as long as the
+// slowest one. Results are in the order they arrive.
+func (engine SearchEngine) Search20(query string) ([]Result, error) {
+	backends, err := engine.firstReplicas()
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan Result, len(backends))
+	for _, backend := range backends {
+		go func(backend Search) { c <- backend(query) }(backend)
+	}
+	var results []Result
+	for range backends {
+		results = append(results, <-c)
+	}
+	return results, nil
+}
+
+// Search21 is Search20, but gives up on slow backends after timeout. The
+// results are then missing the slow ones, and the error is ErrSearchTimeout.
+func (engine SearchEngine) Search21(query string, timeout time.Duration) ([]Result, error) {
+	backends, err := engine.firstReplicas()
+	if err != nil {
+		return nil, err
+	}
+	return engine.collect(query, timeout, backends...)
+}
+
+// Search30 asks every replica of each backend and keeps the first answer,
+// so one slow server no longer makes a result miss the timeout.
+func (engine SearchEngine) Search30(query string, timeout time.Duration) ([]Result, error) {
+	backends, err := engine.backends()
+	if err != nil {
+		return nil, err
+	}
+	replicated := make([]Search, len(backends))
+	for i, replicas := range backends {
+		replicas := replicas
+		replicated[i] = func(query string) Result {
+			// backends checked there is at least one replica.
+			result, _ := First(query, replicas...)
+			return result
+		}
+	}
+	return engine.collect(query, timeout, replicated...)
+}
+
+// collect runs the backends at the same time, and returns the results that
+// arrive before timeout, with ErrSearchTimeout if some did not.
+func (engine SearchEngine) collect(query string, timeout time.Duration, backends ...Search) ([]Result, error) {
+	// Buffered, so backends that answer after the timeout don't leak.
+	c := make(chan Result, len(backends))
+	for _, backend := range backends {
+		go func(backend Search) { c <- backend(query) }(backend)
+	}
+	// One timer for the whole search, not one per result.
+	deadline := orRealClock(engine.Clock).After(timeout)
+	var results []Result
+	for range backends {
+		select {
+		case result := <-c:
+			results = append(results, result)
+		case <-deadline:
+			return results, ErrSearchTimeout
+		}
+	}
+	return results, nil
+}
+
+// SearchMain runs each version of the search and shows how long it took.
+func SearchMain() {
+	replicas := func(kind string) []Search {
+		return []Search{
+			FakeSearch(kind, RandomLatency(100*time.Millisecond)),
+			FakeSearch(kind, RandomLatency(100*time.Millisecond)),
+			FakeSearch(kind, RandomLatency(100*time.Millisecond)),
+		}
+	}
+	engine := SearchEngine{Web: replicas("web"), Image: replicas("image"), Video: replicas("video")}
+
+	timed := func(name string, search func() ([]Result, error)) {
+		start := time.Now()
+		results, err := search()
+		if err != nil {
+			fmt.Println(name, time.Since(start), results, err)
+			return
+		}
+		fmt.Println(name, time.Since(start), results)
+	}
+	timed("Search 1.0", func() ([]Result, error) { return engine.Search10("golang") })
+	timed("Search 2.0", func() ([]Result, error) { return engine.Search20("golang") })
+	timed("Search 2.1", func() ([]Result, error) { return engine.Search21("golang", 80*time.Millisecond) })
+	timed("Search 3.0", func() ([]Result, error) { return engine.Search30("golang", 80*time.Millisecond) })
+}
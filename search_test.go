@@ -0,0 +1,132 @@
+package main
+
+import (
+	stderrors "errors" // errors is taken by the errors() demo.
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fixedLatency is a backend that always takes latency to answer.
+func fixedLatency(kind string, latency time.Duration) Search {
+	return FakeSearch(kind, func() time.Duration { return latency })
+}
+
+// blocked is a backend that answers once release is closed.
+func blocked(kind string, release <-chan struct{}) Search {
+	return func(query string) Result {
+		<-release
+		return Result(kind + " result for " + query)
+	}
+}
+
+func sortedResults(results []Result) []Result {
+	sorted := append([]Result(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+var allResults = []Result{
+	`image result for "golang"`, `video result for "golang"`, `web result for "golang"`,
+}
+
+func TestSearchLatency(t *testing.T) {
+	const latency = 50 * time.Millisecond
+	engine := SearchEngine{
+		Web:   []Search{fixedLatency("web", latency)},
+		Image: []Search{fixedLatency("image", latency)},
+		Video: []Search{fixedLatency("video", latency)},
+	}
+
+	start := time.Now()
+	results, err := engine.Search10("golang")
+	elapsed := time.Since(start)
+	// One after the other, in order.
+	want := []Result{`web result for "golang"`, `image result for "golang"`, `video result for "golang"`}
+	if err != nil || !reflect.DeepEqual(results, want) {
+		t.Errorf("Search10 = %v, %v, want %v", results, err, want)
+	}
+	if elapsed < 3*latency {
+		t.Errorf("Search10 took %v, less than the 3 backends one after the other", elapsed)
+	}
+
+	start = time.Now()
+	results, err = engine.Search20("golang")
+	elapsed = time.Since(start)
+	if err != nil || !reflect.DeepEqual(sortedResults(results), allResults) {
+		t.Errorf("Search20 = %v, %v, want all 3", results, err)
+	}
+	if elapsed < latency || elapsed >= 3*latency {
+		t.Errorf("Search20 took %v, want about %v for the backends at once", elapsed, latency)
+	}
+}
+
+func TestSearch21TimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	engine := SearchEngine{
+		Web:   []Search{fixedLatency("web", 0)},
+		Image: []Search{fixedLatency("image", 0)},
+		Video: []Search{blocked("video", release)},
+	}
+	const timeout = 50 * time.Millisecond
+	start := time.Now()
+	results, err := engine.Search21("golang", timeout)
+	elapsed := time.Since(start)
+	if err != ErrSearchTimeout {
+		t.Errorf("Search21 error = %v, want %v", err, ErrSearchTimeout)
+	}
+	// The fast ones made it, the blocked one did not.
+	if want := []Result{allResults[0], allResults[2]}; !reflect.DeepEqual(sortedResults(results), want) {
+		t.Errorf("Search21 = %v, want the image and web results", results)
+	}
+	if elapsed < timeout || elapsed > 20*timeout {
+		t.Errorf("Search21 took %v, want about the %v timeout", elapsed, timeout)
+	}
+}
+
+func TestSearch30Replicas(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	// Every backend has a server that never answers in time, and one that
+	// answers right away.
+	replicas := func(kind string) []Search {
+		return []Search{blocked(kind, release), fixedLatency(kind, 0), blocked(kind, release)}
+	}
+	// The fake clock never moves, so the only way to finish is with every
+	// result.
+	engine := SearchEngine{
+		Web: replicas("web"), Image: replicas("image"), Video: replicas("video"),
+		Clock: newFakeClock(),
+	}
+	results, err := engine.Search30("golang", time.Second)
+	if err != nil || !reflect.DeepEqual(sortedResults(results), allResults) {
+		t.Errorf("Search30 = %v, %v, want all 3", results, err)
+	}
+	// Search21 only asks the first replica, which never answers.
+	engine.Clock = nil
+	results, err = engine.Search21("golang", 10*time.Millisecond)
+	if err != ErrSearchTimeout || len(results) != 0 {
+		t.Errorf("Search21 = %v, %v, want nothing and a timeout", results, err)
+	}
+}
+
+func TestSearchNoReplicas(t *testing.T) {
+	backend := []Search{fixedLatency("any", 0)}
+	engine := SearchEngine{Web: backend, Image: nil, Video: backend}
+	searches := map[string]func() ([]Result, error){
+		"Search10": func() ([]Result, error) { return engine.Search10("golang") },
+		"Search20": func() ([]Result, error) { return engine.Search20("golang") },
+		"Search21": func() ([]Result, error) { return engine.Search21("golang", time.Second) },
+		"Search30": func() ([]Result, error) { return engine.Search30("golang", time.Second) },
+	}
+	for name, search := range searches {
+		if _, err := search(); !stderrors.Is(err, ErrNoReplicas) {
+			t.Errorf("%s error = %v, want %v", name, err, ErrNoReplicas)
+		}
+	}
+	if _, err := First("golang"); err != ErrNoReplicas {
+		t.Errorf("First with no replicas error = %v, want %v", err, ErrNoReplicas)
+	}
+}
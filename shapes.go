@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Shape is like Abser: nothing declares that it implements it. Polygon,
+// Circle, Rect and Segment just happen to have these methods.
+type Shape interface {
+	Area() float64
+	Perimeter() float64
+	// BoundingBox is the smallest Rect around the shape.
+	BoundingBox() Rect
+	// Contains reports whether p is inside the shape or on its edge.
+	Contains(p Vertex) bool
+}
+
+// onEdgeEpsilon is how close to an edge a point must be to count as on it.
+const onEdgeEpsilon = 1e-9
+
+// Rect is an axis-aligned rectangle from corner Min to corner Max.
+type Rect struct {
+	Min, Max Vertex
+}
+
+// Area returns the area of r.
+func (r Rect) Area() float64 {
+	return (r.Max.X - r.Min.X) * (r.Max.Y - r.Min.Y)
+}
+
+// Perimeter returns the length around r.
+func (r Rect) Perimeter() float64 {
+	return 2 * ((r.Max.X - r.Min.X) + (r.Max.Y - r.Min.Y))
+}
+
+// BoundingBox returns r itself.
+func (r Rect) BoundingBox() Rect {
+	return r
+}
+
+// Contains reports whether p is inside r or on its edge.
+func (r Rect) Contains(p Vertex) bool {
+	return r.Min.X <= p.X && p.X <= r.Max.X && r.Min.Y <= p.Y && p.Y <= r.Max.Y
+}
+
+// Circle is the disk of radius Radius around Center.
+type Circle struct {
+	Center Vertex
+	Radius float64
+}
+
+// Area returns the area of c.
+func (c Circle) Area() float64 {
+	return math.Pi * c.Radius * c.Radius
+}
+
+// Perimeter returns the circumference of c.
+func (c Circle) Perimeter() float64 {
+	return 2 * math.Pi * c.Radius
+}
+
+// BoundingBox returns the square around c.
+func (c Circle) BoundingBox() Rect {
+	r := Vertex{c.Radius, c.Radius}
+	return Rect{c.Center.Sub(r), c.Center.Add(r)}
+}
+
+// Contains reports whether p is inside c or on its edge.
+func (c Circle) Contains(p Vertex) bool {
+	return c.Center.Distance(p) <= c.Radius+onEdgeEpsilon
+}
+
+// Segment is the straight line between A and B.
+type Segment struct {
+	A, B Vertex
+}
+
+// Area of a line is 0.
+func (s Segment) Area() float64 {
+	return 0
+}
+
+// Perimeter returns the length of s.
+func (s Segment) Perimeter() float64 {
+	return s.A.Distance(s.B)
+}
+
+// BoundingBox returns the Rect with s as a diagonal.
+func (s Segment) BoundingBox() Rect {
+	return Rect{
+		Vertex{math.Min(s.A.X, s.B.X), math.Min(s.A.Y, s.B.Y)},
+		Vertex{math.Max(s.A.X, s.B.X), math.Max(s.A.Y, s.B.Y)},
+	}
+}
+
+// Contains reports whether p lies on s.
+func (s Segment) Contains(p Vertex) bool {
+	ab, ap := s.B.Sub(s.A), p.Sub(s.A)
+	if ab == (Vertex{}) {
+		// A and B are the same point, so the cross product says nothing.
+		return p.Equal(s.A, onEdgeEpsilon)
+	}
+	// p must be in line with A and B (cross product 0), and between them.
+	if math.Abs(ab.Cross(ap)) > onEdgeEpsilon*math.Max(1, ab.abs()) {
+		return false
+	}
+	t := ab.Dot(ap)
+	return -onEdgeEpsilon <= t && t <= ab.Dot(ab)+onEdgeEpsilon
+}
+
+// Polygon is the closed shape through Points, in order. The last point
+// connects back to the first. The edges must not cross each other.
+type Polygon struct {
+	Points []Vertex
+}
+
+// edges returns every side of p, including the closing one.
+func (p Polygon) edges() []Segment {
+	edges := make([]Segment, len(p.Points))
+	for i, a := range p.Points {
+		edges[i] = Segment{a, p.Points[(i+1)%len(p.Points)]}
+	}
+	return edges
+}
+
+// signedArea is the shoelace formula: positive when the points go
+// counterclockwise, negative when clockwise.
+func (p Polygon) signedArea() float64 {
+	sum := 0.0
+	for _, edge := range p.edges() {
+		sum += edge.A.Cross(edge.B)
+	}
+	return sum / 2
+}
+
+// Area returns the area of p.
+func (p Polygon) Area() float64 {
+	return math.Abs(p.signedArea())
+}
+
+// Perimeter returns the length around p.
+func (p Polygon) Perimeter() float64 {
+	sum := 0.0
+	for _, edge := range p.edges() {
+		sum += edge.Perimeter()
+	}
+	return sum
+}
+
+// BoundingBox returns the smallest Rect around the points of p.
+func (p Polygon) BoundingBox() Rect {
+	if len(p.Points) == 0 {
+		return Rect{}
+	}
+	box := Rect{p.Points[0], p.Points[0]}
+	for _, point := range p.Points[1:] {
+		box.Min = Vertex{math.Min(box.Min.X, point.X), math.Min(box.Min.Y, point.Y)}
+		box.Max = Vertex{math.Max(box.Max.X, point.X), math.Max(box.Max.Y, point.Y)}
+	}
+	return box
+}
+
+// Contains reports whether point is inside p or on its edge. It casts a ray
+// from point to the right and counts the edges it crosses: an odd count
+// means inside.
+func (p Polygon) Contains(point Vertex) bool {
+	inside := false
+	for _, edge := range p.edges() {
+		if edge.Contains(point) {
+			return true
+		}
+		a, b := edge.A, edge.B
+		// Does the edge straddle the ray's height? Counting the lower end
+		// but not the upper one means a ray through a corner counts once.
+		if (a.Y > point.Y) != (b.Y > point.Y) {
+			// Where the edge meets the ray's height.
+			x := a.X + (point.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+			if x > point.X {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// Centroid returns the center of mass of p. A polygon with no area has no
+// such center, so the average of its points is returned instead.
+func (p Polygon) Centroid() Vertex {
+	area := p.signedArea()
+	if area == 0 {
+		var sum Vertex
+		for _, point := range p.Points {
+			sum = sum.Add(point)
+		}
+		if len(p.Points) > 0 {
+			sum = sum.Scale(1 / float64(len(p.Points)))
+		}
+		return sum
+	}
+	var cx, cy float64
+	for _, edge := range p.edges() {
+		cross := edge.A.Cross(edge.B)
+		cx += (edge.A.X + edge.B.X) * cross
+		cy += (edge.A.Y + edge.B.Y) * cross
+	}
+	return Vertex{cx / (6 * area), cy / (6 * area)}
+}
+
+func shapes() {
+	// An L made of three unit squares.
+	l := Polygon{[]Vertex{{0, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 2}, {0, 2}}}
+	all := []Shape{
+		l,
+		Circle{Vertex{0, 0}, 1},
+		Rect{Vertex{0, 0}, Vertex{2, 1}},
+		Segment{Vertex{0, 0}, Vertex{3, 4}},
+	}
+	for _, shape := range all {
+		fmt.Printf("%T area=%.3f perimeter=%.3f box=%v contains (0.6, 0.8)=%v\n",
+			shape, shape.Area(), shape.Perimeter(), shape.BoundingBox(), shape.Contains(Vertex{0.6, 0.8}))
+	}
+	fmt.Println("Centroid of the L:", l.Centroid())
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+)
+
+// lShape is an L made of three unit squares, counterclockwise.
+var lShape = Polygon{[]Vertex{{0, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 2}, {0, 2}}}
+
+func reversed(p Polygon) Polygon {
+	points := make([]Vertex, len(p.Points))
+	for i, point := range p.Points {
+		points[len(points)-1-i] = point
+	}
+	return Polygon{points}
+}
+
+func TestShapeMeasures(t *testing.T) {
+	tests := []struct {
+		name            string
+		shape           Shape
+		area, perimeter float64
+		box             Rect
+	}{
+		{"L", lShape, 3, 8, Rect{Vertex{0, 0}, Vertex{2, 2}}},
+		{"L clockwise", reversed(lShape), 3, 8, Rect{Vertex{0, 0}, Vertex{2, 2}}},
+		{"unit square", Polygon{[]Vertex{{0, 0}, {1, 0}, {1, 1}, {0, 1}}}, 1, 4, Rect{Vertex{0, 0}, Vertex{1, 1}}},
+		{"triangle", Polygon{[]Vertex{{0, 0}, {4, 0}, {0, 3}}}, 6, 12, Rect{Vertex{0, 0}, Vertex{4, 3}}},
+		{"polygon of one point", Polygon{[]Vertex{{1, 2}}}, 0, 0, Rect{Vertex{1, 2}, Vertex{1, 2}}},
+		{"empty polygon", Polygon{}, 0, 0, Rect{}},
+		{"unit circle", Circle{Vertex{0, 0}, 1}, math.Pi, 2 * math.Pi, Rect{Vertex{-1, -1}, Vertex{1, 1}}},
+		{"circle", Circle{Vertex{3, -1}, 2}, 4 * math.Pi, 4 * math.Pi, Rect{Vertex{1, -3}, Vertex{5, 1}}},
+		{"rect", Rect{Vertex{0, 0}, Vertex{2, 1}}, 2, 6, Rect{Vertex{0, 0}, Vertex{2, 1}}},
+		{"segment", Segment{Vertex{3, 4}, Vertex{0, 0}}, 0, 5, Rect{Vertex{0, 0}, Vertex{3, 4}}},
+		{"segment of one point", Segment{Vertex{1, 1}, Vertex{1, 1}}, 0, 0, Rect{Vertex{1, 1}, Vertex{1, 1}}},
+	}
+	for _, test := range tests {
+		if got := test.shape.Area(); math.Abs(got-test.area) > 1e-12 {
+			t.Errorf("%s: Area() = %v, want %v", test.name, got, test.area)
+		}
+		if got := test.shape.Perimeter(); math.Abs(got-test.perimeter) > 1e-12 {
+			t.Errorf("%s: Perimeter() = %v, want %v", test.name, got, test.perimeter)
+		}
+		if got := test.shape.BoundingBox(); got != test.box {
+			t.Errorf("%s: BoundingBox() = %v, want %v", test.name, got, test.box)
+		}
+	}
+}
+
+func TestShapeContains(t *testing.T) {
+	tests := []struct {
+		name  string
+		shape Shape
+		p     Vertex
+		want  bool
+	}{
+		{"L inside", lShape, Vertex{0.5, 1.5}, true},
+		{"L in the notch", lShape, Vertex{1.5, 1.5}, false},
+		{"L on the inner corner", lShape, Vertex{1, 1}, true},
+		{"L on an edge", lShape, Vertex{2, 0.5}, true},
+		{"L on a corner", lShape, Vertex{0, 0}, true},
+		// The ray from here passes through the corners at y = 1.
+		{"L left of a corner's height", lShape, Vertex{-1, 1}, false},
+		{"L right of it", lShape, Vertex{3, 1}, false},
+		{"L clockwise inside", reversed(lShape), Vertex{1.5, 0.5}, true},
+		{"L clockwise in the notch", reversed(lShape), Vertex{1.5, 1.5}, false},
+		{"empty polygon", Polygon{}, Vertex{0, 0}, false},
+		{"circle center", Circle{Vertex{1, 1}, 1}, Vertex{1, 1}, true},
+		{"circle edge", Circle{Vertex{0, 0}, 1}, Vertex{0.6, 0.8}, true},
+		{"circle just outside", Circle{Vertex{0, 0}, 1}, Vertex{0.6, 0.81}, false},
+		{"circle box corner", Circle{Vertex{0, 0}, 1}, Vertex{0.9, 0.9}, false},
+		{"rect corner", Rect{Vertex{0, 0}, Vertex{2, 1}}, Vertex{2, 1}, true},
+		{"rect outside", Rect{Vertex{0, 0}, Vertex{2, 1}}, Vertex{2, 1.1}, false},
+		{"segment middle", Segment{Vertex{0, 0}, Vertex{3, 4}}, Vertex{1.5, 2}, true},
+		{"segment end", Segment{Vertex{0, 0}, Vertex{3, 4}}, Vertex{3, 4}, true},
+		{"segment in line but beyond", Segment{Vertex{0, 0}, Vertex{3, 4}}, Vertex{6, 8}, false},
+		{"segment in line but before", Segment{Vertex{0, 0}, Vertex{3, 4}}, Vertex{-3, -4}, false},
+		{"segment beside", Segment{Vertex{0, 0}, Vertex{3, 4}}, Vertex{1.5, 2.1}, false},
+		{"segment of one point, on it", Segment{Vertex{1, 1}, Vertex{1, 1}}, Vertex{1, 1}, true},
+		{"segment of one point, off it", Segment{Vertex{1, 1}, Vertex{1, 1}}, Vertex{1, 2}, false},
+	}
+	for _, test := range tests {
+		if got := test.shape.Contains(test.p); got != test.want {
+			t.Errorf("%s: Contains(%v) = %v, want %v", test.name, test.p, got, test.want)
+		}
+	}
+}
+
+func TestPolygonCentroid(t *testing.T) {
+	tests := []struct {
+		name    string
+		polygon Polygon
+		want    Vertex
+	}{
+		{"L", lShape, Vertex{5.0 / 6, 5.0 / 6}},
+		{"L clockwise", reversed(lShape), Vertex{5.0 / 6, 5.0 / 6}},
+		{"triangle", Polygon{[]Vertex{{0, 0}, {3, 0}, {0, 3}}}, Vertex{1, 1}},
+		// No area: the average of the points.
+		{"flat", Polygon{[]Vertex{{0, 0}, {1, 0}, {5, 0}}}, Vertex{2, 0}},
+		{"empty", Polygon{}, Vertex{0, 0}},
+	}
+	for _, test := range tests {
+		if got := test.polygon.Centroid(); !got.Equal(test.want, 1e-12) {
+			t.Errorf("%s: Centroid() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestShapeProperties(t *testing.T) {
+	// rect is the Rect and the Polygon with corners (x0, y0) and
+	// (x0+w+1, y0+h+1), so never flat.
+	rect := func(x0, y0 int16, w, h uint8) (Rect, Polygon) {
+		lo := small(x0, y0)
+		hi := lo.Add(Vertex{float64(w) + 1, float64(h) + 1})
+		return Rect{lo, hi}, Polygon{[]Vertex{lo, {hi.X, lo.Y}, hi, {lo.X, hi.Y}}}
+	}
+	properties := map[string]any{
+		"a rectangular Polygon measures like the Rect": func(x0, y0 int16, w, h uint8) bool {
+			r, p := rect(x0, y0, w, h)
+			return p.Area() == r.Area() && p.Perimeter() == r.Perimeter() && p.BoundingBox() == r &&
+				p.Centroid().Equal(r.Min.Lerp(r.Max, 0.5), 1e-9)
+		},
+		"a rectangular Polygon contains what the Rect does": func(x0, y0 int16, w, h uint8, px, py int16) bool {
+			r, p := rect(x0, y0, w, h)
+			// Points near the rectangle, on a grid that hits its edges.
+			point := r.Min.Add(Vertex{float64(px%300) / 2, float64(py%300) / 2})
+			return p.Contains(point) == r.Contains(point)
+		},
+		"moving a polygon keeps its area": func(dx, dy int16) bool {
+			moved := Polygon{make([]Vertex, len(lShape.Points))}
+			for i, point := range lShape.Points {
+				moved.Points[i] = point.Add(small(dx, dy))
+			}
+			return math.Abs(moved.Area()-lShape.Area()) < 1e-6 &&
+				moved.Centroid().Equal(lShape.Centroid().Add(small(dx, dy)), 1e-6)
+		},
+		"a segment contains the points between its ends, and its box its ends": func(ax, ay, bx, by int16, t uint16) bool {
+			s := Segment{small(ax, ay), small(bx, by)}
+			p := s.A.Lerp(s.B, float64(t)/math.MaxUint16)
+			return s.Contains(p) && s.BoundingBox().Contains(s.A) && s.BoundingBox().Contains(s.B)
+		},
+		"a circle contains its rim and not beyond": func(cx, cy int16, radius uint8, angle float64) bool {
+			c := Circle{small(cx, cy), float64(radius)}
+			rim := c.Center.Add(Vertex{c.Radius, 0}.Rotate(math.Mod(angle, 10)))
+			beyond := c.Center.Add(Vertex{c.Radius + 0.01, 0}.Rotate(math.Mod(angle, 10)))
+			return c.Contains(c.Center) && c.Contains(rim) && !c.Contains(beyond)
+		},
+	}
+	for name, property := range properties {
+		if err := quick.Check(property, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"hash/fnv"
+	"io"
+)
+
+// shardFor hashes key onto one of n shards.
+func shardFor(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// ShardedSafeCache spreads urls over several SafeCaches, each with its own
+// mutex. Goroutines working on different urls then rarely wait on each other,
+// while one SafeCache makes every Add and Get take turns.
+type ShardedSafeCache struct {
+	shards []*SafeCache
+}
+
+// NewShardedSafeCache returns a cache split into n shards. configure, if not
+// nil, is called on each shard before use, e.g. to set Capacity or TTL. Note
+// such limits then apply per shard.
+func NewShardedSafeCache(n int, configure func(*SafeCache)) *ShardedSafeCache {
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]*SafeCache, n)
+	for i := range shards {
+		shards[i] = &SafeCache{}
+		if configure != nil {
+			configure(shards[i])
+		}
+	}
+	return &ShardedSafeCache{shards}
+}
+
+// Add safely to cache.
+func (c *ShardedSafeCache) Add(url string, body string) {
+	c.shards[shardFor(url, len(c.shards))].Add(url, body)
+}
+
+// Get from cache.
+func (c *ShardedSafeCache) Get(url string) (body string, ok bool) {
+	return c.shards[shardFor(url, len(c.shards))].Get(url)
+}
+
+// Stats adds up the counters of all shards.
+func (c *ShardedSafeCache) Stats() CacheStats {
+	var total CacheStats
+	for _, shard := range c.shards {
+		stats := shard.Stats()
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Evictions += stats.Evictions
+		total.Expirations += stats.Expirations
+	}
+	return total
+}
+
+// ShardedSafeCounter is a SafeCounter split into lock-striped shards. It
+// implements Counter, so it can count crawl outcomes too.
+type ShardedSafeCounter struct {
+	shards []*SafeCounter
+}
+
+// NewShardedSafeCounter returns a counter split into n shards.
+func NewShardedSafeCounter(n int) *ShardedSafeCounter {
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]*SafeCounter, n)
+	for i := range shards {
+		shards[i] = &SafeCounter{m: make(map[string]int)}
+	}
+	return &ShardedSafeCounter{shards}
+}
+
+// Inc increments the counter for the given key.
+func (counter *ShardedSafeCounter) Inc(key string) {
+	counter.shard(key).Inc(key)
+}
+
+// Value returns the current value of the counter for the given key.
+func (counter *ShardedSafeCounter) Value(key string) int {
+	return counter.shard(key).Value(key)
+}
+
+// shard returns the SafeCounter that holds key.
+func (counter *ShardedSafeCounter) shard(key string) *SafeCounter {
+	return counter.shards[shardFor(key, len(counter.shards))]
+}
+
+// Add adds n to the counter for the given key. n may be negative.
+func (counter *ShardedSafeCounter) Add(key string, n int) {
+	counter.shard(key).Add(key, n)
+}
+
+// Delete removes the given key.
+func (counter *ShardedSafeCounter) Delete(key string) {
+	counter.shard(key).Delete(key)
+}
+
+// lockAll locks every shard, always in the same order so two callers can't
+// deadlock each other.
+func (counter *ShardedSafeCounter) lockAll() {
+	for _, shard := range counter.shards {
+		shard.mux.Lock()
+	}
+}
+
+func (counter *ShardedSafeCounter) unlockAll() {
+	for _, shard := range counter.shards {
+		shard.mux.Unlock()
+	}
+}
+
+// Reset removes every key, from all shards at once.
+func (counter *ShardedSafeCounter) Reset() {
+	counter.lockAll()
+	defer counter.unlockAll()
+	for _, shard := range counter.shards {
+		shard.m = make(map[string]int)
+	}
+}
+
+// Snapshot returns a copy of every counter. All shards are locked while it
+// copies, so like SafeCounter's it is one consistent view.
+func (counter *ShardedSafeCounter) Snapshot() map[string]int {
+	counter.lockAll()
+	defer counter.unlockAll()
+	snapshot := make(map[string]int)
+	for _, shard := range counter.shards {
+		for key, value := range shard.m {
+			snapshot[key] = value
+		}
+	}
+	return snapshot
+}
+
+// TopK returns the k keys with the highest values, highest first.
+func (counter *ShardedSafeCounter) TopK(k int) []CounterEntry {
+	return topK(counter.Snapshot(), k)
+}
+
+// WriteText writes one "key value" line per key, sorted by key.
+func (counter *ShardedSafeCounter) WriteText(w io.Writer) error {
+	return writeCounterText(w, counter.Snapshot())
+}
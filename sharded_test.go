@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+var _ Counter = (*ShardedSafeCounter)(nil)
+
+func TestShardedSafeCache(t *testing.T) {
+	cache := NewShardedSafeCache(8, func(shard *SafeCache) { shard.Capacity = 100 })
+	for i := 0; i < 50; i++ {
+		url := fmt.Sprintf("https://golang.org/%d/", i)
+		cache.Add(url, url)
+	}
+	for i := 0; i < 60; i++ {
+		url := fmt.Sprintf("https://golang.org/%d/", i)
+		if body, ok := cache.Get(url); ok != (i < 50) || (ok && body != url) {
+			t.Errorf("Get(%q) = %q, %v", url, body, ok)
+		}
+	}
+	if want := (CacheStats{Hits: 50, Misses: 10}); cache.Stats() != want {
+		t.Errorf("Stats() = %+v, want %+v", cache.Stats(), want)
+	}
+}
+
+func TestShardedSafeCounter(t *testing.T) {
+	counter := NewShardedSafeCounter(4)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				counter.Inc(fmt.Sprint("key", i%10))
+				counter.Add("total", 2)
+			}
+		}()
+	}
+	wg.Wait()
+	want := map[string]int{"total": 1600}
+	for i := 0; i < 10; i++ {
+		want[fmt.Sprint("key", i)] = 80
+	}
+	if got := counter.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v, want %v", got, want)
+	}
+	if got := counter.TopK(2); !reflect.DeepEqual(got, []CounterEntry{{"total", 1600}, {"key0", 80}}) {
+		t.Errorf("TopK(2) = %v", got)
+	}
+	counter.Delete("total")
+	if counter.Value("total") != 0 || len(counter.Snapshot()) != 10 {
+		t.Errorf("after Delete: %v", counter.Snapshot())
+	}
+	counter.Reset()
+	if got := counter.Snapshot(); len(got) != 0 {
+		t.Errorf("after Reset: %v", got)
+	}
+}
+
+// benchKeys are the URLs the benchmarks work on.
+var benchKeys = func() []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("https://golang.org/pkg/%d/", i)
+	}
+	return keys
+}()
+
+// goroutineCounts are the parallelism settings of the benchmarks.
+// b.RunParallel starts parallelism * GOMAXPROCS goroutines, so run with
+// -cpu=1 to get exactly 1, 4, 16 and 64.
+var goroutineCounts = []int{1, 4, 16, 64}
+
+// benchParallel runs op from each goroutine count in goroutineCounts. op
+// gets an ever increasing i, starting at a different point for every
+// goroutine so they don't all hit the same key at once.
+func benchParallel(b *testing.B, op func(i int)) {
+	for _, parallelism := range goroutineCounts {
+		b.Run(fmt.Sprintf("goroutines=%d", parallelism*runtime.GOMAXPROCS(0)), func(b *testing.B) {
+			var offset atomic.Int64
+			b.SetParallelism(parallelism)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(offset.Add(97))
+				for pb.Next() {
+					op(i)
+					i++
+				}
+			})
+		})
+	}
+}
+
+// The cache benchmarks do what a crawl does: mostly Get, and an Add when a
+// page is new. One op in 10 is an Add.
+
+func BenchmarkCacheSafeCache(b *testing.B) {
+	cache := &SafeCache{}
+	benchParallel(b, func(i int) {
+		key := benchKeys[i%len(benchKeys)]
+		if i%10 == 0 {
+			cache.Add(key, key)
+		} else {
+			cache.Get(key)
+		}
+	})
+}
+
+func BenchmarkCacheShardedSafeCache(b *testing.B) {
+	cache := NewShardedSafeCache(32, nil)
+	benchParallel(b, func(i int) {
+		key := benchKeys[i%len(benchKeys)]
+		if i%10 == 0 {
+			cache.Add(key, key)
+		} else {
+			cache.Get(key)
+		}
+	})
+}
+
+func BenchmarkCacheSyncMap(b *testing.B) {
+	var cache sync.Map
+	benchParallel(b, func(i int) {
+		key := benchKeys[i%len(benchKeys)]
+		if i%10 == 0 {
+			cache.Store(key, key)
+		} else {
+			cache.Load(key)
+		}
+	})
+}
+
+// The counter benchmarks only Inc. A sync.Map can't increment a plain int
+// in place, so the sync.Map counter stores an *atomic.Int64 per key.
+
+func BenchmarkCounterSafeCounter(b *testing.B) {
+	counter := &SafeCounter{m: make(map[string]int)}
+	benchParallel(b, func(i int) { counter.Inc(benchKeys[i%len(benchKeys)]) })
+}
+
+func BenchmarkCounterShardedSafeCounter(b *testing.B) {
+	counter := NewShardedSafeCounter(32)
+	benchParallel(b, func(i int) { counter.Inc(benchKeys[i%len(benchKeys)]) })
+}
+
+func BenchmarkCounterSyncMap(b *testing.B) {
+	var counter sync.Map
+	benchParallel(b, func(i int) {
+		value, _ := counter.LoadOrStore(benchKeys[i%len(benchKeys)], new(atomic.Int64))
+		value.(*atomic.Int64).Add(1)
+	})
+}
+
+// BenchmarkCounterAtomicCounter is BenchmarkCounterSyncMap behind the
+// Counter methods, with a Load before the LoadOrStore.
+func BenchmarkCounterAtomicCounter(b *testing.B) {
+	counter := &AtomicCounter{}
+	benchParallel(b, func(i int) { counter.Inc(benchKeys[i%len(benchKeys)]) })
+}
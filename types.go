@@ -3,10 +3,9 @@ package main
 import "fmt"
 import "strings"
 
-type vertex struct {
-	x int
-	y int
-}
+// vertex is the int flavor of Point, see point.go. It used to be its own
+// struct with lowercase x and y.
+type vertex = Point[int]
 
 func pointers() {
 	// Pointers work similar to C, but no pointer arithmatic.
@@ -22,18 +21,18 @@ func structs() {
 	// Structs
 	v := vertex{1, 2}
 	fmt.Println("Vertex struct is:", v)
-	fmt.Println("Vertex x is:", v.x)
+	fmt.Println("Vertex x is:", v.X)
 
 	// Pointers to Structs. You can derefernce normally or
 	// dereference and access using just dot.
 	structPointer := &v
-	fmt.Println("Vertex x is:", (*structPointer).x)
-	fmt.Println("Vertex x is:", structPointer.x)
+	fmt.Println("Vertex x is:", (*structPointer).X)
+	fmt.Println("Vertex x is:", structPointer.X)
 
 	// Create struct literal by referencing field.
 	var (
 		v1        = vertex{1, 2}  // has type Vertex
-		v2        = vertex{x: 1}  // Y:0 is implicit
+		v2        = vertex{X: 1}  // Y:0 is implicit
 		v3        = vertex{}      // X:0 and Y:0
 		v1Pointer = &vertex{1, 2} // has type *Vertex
 	)
@@ -291,6 +290,7 @@ func TypesMain() {
 	rangeLoop()
 	printSlicesExercise(SlicesExercise)
 	maps()
+	spatialIndex()
 	fmt.Println(wordCount("This This is is a string"))
 	functionValues()
 	callClosure()
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// URLNormalizer rewrites URLs into a canonical form so the crawler can tell
+// that two spellings of the same address point at the same page.
+// e.g. "https://GOLANG.org:443/pkg/#frag" and "https://golang.org/pkg/" are
+// both turned into "https://golang.org/pkg/".
+type URLNormalizer struct {
+	// SortQuery orders query parameters by key, so "?b=2&a=1" and "?a=1&b=2"
+	// are treated as the same URL. Off by default, since some sites care
+	// about parameter order.
+	SortQuery bool
+	// MergeTrailingSlash drops the trailing slash of every path but "/", so
+	// "/pkg/" and "/pkg" are treated as the same URL, "/pkg". Off by
+	// default, since only the server can say they are the same resource.
+	MergeTrailingSlash bool
+}
+
+// defaultPorts are dropped from the host, because they are implied by the scheme.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize returns the canonical form of rawURL. It lowercases the scheme and
+// host, strips the fragment and any default port, and resolves "." and ".."
+// segments. Trailing slashes are left as written unless MergeTrailingSlash is
+// set: "/pkg" and "/pkg/" may be different resources.
+func (n URLNormalizer) Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	// The fragment never reaches the server, so it can't change the page.
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		// Hostname() strips the brackets off IPv6 literals, put them back.
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	// Clean the escaped form, so an encoded slash like "/a%2Fb" stays part of
+	// its segment instead of becoming a separator.
+	escaped := cleanPath(u.EscapedPath(), u.Host != "")
+	if n.MergeTrailingSlash && escaped != "/" && strings.HasPrefix(escaped, "/") {
+		escaped = strings.TrimSuffix(escaped, "/")
+	}
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		return "", err
+	}
+	u.Path, u.RawPath = unescaped, escaped
+
+	if n.SortQuery && u.RawQuery != "" {
+		// Encode() sorts by key, and keeps the order of repeated keys.
+		u.RawQuery = u.Query().Encode()
+	}
+	return u.String(), nil
+}
+
+// cleanPath resolves "." and ".." in the escaped path p. A path on a host is
+// never empty, so "https://golang.org" becomes "https://golang.org/". A
+// trailing slash is kept if p had one. Relative paths like "a/../b" are
+// returned as is, since what ".." means depends on the page they are on.
+func cleanPath(p string, hasHost bool) string {
+	if p == "" {
+		if hasHost {
+			return "/"
+		}
+		return ""
+	}
+	if !strings.HasPrefix(p, "/") {
+		return p
+	}
+	trailing := strings.HasSuffix(p, "/") ||
+		strings.HasSuffix(p, "/.") || strings.HasSuffix(p, "/..")
+	cleaned := path.Clean(p)
+	if trailing && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// normalizeURL normalizes with the default options. If the URL can't be parsed
+// it is returned as is, so the fetcher gets to report the error.
+func normalizeURL(rawURL string) string {
+	normalized, err := URLNormalizer{}.Normalize(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return normalized
+}
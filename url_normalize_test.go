@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+func TestURLNormalizer(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		sortQuery bool
+		want      string
+	}{
+		{"already normal", "https://golang.org/pkg/", false, "https://golang.org/pkg/"},
+		{"uppercase host", "https://GOLANG.org/pkg/", false, "https://golang.org/pkg/"},
+		{"uppercase scheme", "HTTPS://golang.org/pkg/", false, "https://golang.org/pkg/"},
+		{"path case kept", "https://golang.org/Pkg/", false, "https://golang.org/Pkg/"},
+		{"fragment", "https://golang.org/pkg/#frag", false, "https://golang.org/pkg/"},
+		{"empty fragment", "https://golang.org/pkg/#", false, "https://golang.org/pkg/"},
+		{"default https port", "https://golang.org:443/pkg/", false, "https://golang.org/pkg/"},
+		{"default http port", "http://golang.org:80/pkg/", false, "http://golang.org/pkg/"},
+		{"other port kept", "https://golang.org:8443/pkg/", false, "https://golang.org:8443/pkg/"},
+		{"http port on https kept", "https://golang.org:80/", false, "https://golang.org:80/"},
+		{"ipv6 host", "http://[::1]:80/a", false, "http://[::1]/a"},
+		{"ipv6 host with port", "http://[::1]:8080/a", false, "http://[::1]:8080/a"},
+		{"empty path", "https://golang.org", false, "https://golang.org/"},
+		{"no trailing slash kept", "https://golang.org/pkg", false, "https://golang.org/pkg"},
+		{"no trailing slash api", "https://example.com/api/users", false, "https://example.com/api/users"},
+		{"file kept", "https://golang.org/doc/go1.html", false, "https://golang.org/doc/go1.html"},
+		{"dot segment", "https://golang.org/./pkg/", false, "https://golang.org/pkg/"},
+		{"dot dot segment", "https://golang.org/cmd/../pkg/", false, "https://golang.org/pkg/"},
+		{"dot dot past root", "https://golang.org/../pkg/", false, "https://golang.org/pkg/"},
+		{"trailing dot dot", "https://golang.org/pkg/fmt/..", false, "https://golang.org/pkg/"},
+		{"trailing dot", "https://golang.org/pkg/.", false, "https://golang.org/pkg/"},
+		{"double slash", "https://golang.org//pkg//fmt/", false, "https://golang.org/pkg/fmt/"},
+		{"encoded slash kept", "https://example.com/a%2Fb", false, "https://example.com/a%2Fb"},
+		{"encoded slash with dots", "https://example.com/x/../a%2Fb/", false, "https://example.com/a%2Fb/"},
+		{"encoded space", "https://example.com/a%20b", false, "https://example.com/a%20b"},
+		{"query kept in order", "https://golang.org/?b=2&a=1", false, "https://golang.org/?b=2&a=1"},
+		{"query sorted", "https://golang.org/?b=2&a=1", true, "https://golang.org/?a=1&b=2"},
+		{"repeated keys keep order", "https://golang.org/?a=2&b=1&a=1", true, "https://golang.org/?a=2&a=1&b=1"},
+		{"whitespace", "  https://golang.org/pkg/ ", false, "https://golang.org/pkg/"},
+		{"relative path left alone", "relative/path", false, "relative/path"},
+		{"relative dot dot left alone", "a/../b", false, "a/../b"},
+		{"absolute path without host", "/a/./b/", false, "/a/b/"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := URLNormalizer{SortQuery: test.sortQuery}.Normalize(test.in)
+			if err != nil {
+				t.Fatalf("Normalize(%q) error: %v", test.in, err)
+			}
+			if got != test.want {
+				t.Errorf("Normalize(%q) = %q, want %q", test.in, got, test.want)
+			}
+			// Normalizing twice must not change anything.
+			again, err := URLNormalizer{SortQuery: test.sortQuery}.Normalize(got)
+			if err != nil || again != got {
+				t.Errorf("Normalize(%q) = %q, %v, want it unchanged", got, again, err)
+			}
+		})
+	}
+}
+
+func TestURLNormalizerMergeTrailingSlash(t *testing.T) {
+	n := URLNormalizer{MergeTrailingSlash: true}
+	tests := []struct {
+		in, want string
+	}{
+		// The duplicate from the crawl: both spellings become one URL.
+		{"https://golang.org/pkg/", "https://golang.org/pkg"},
+		{"https://golang.org/pkg", "https://golang.org/pkg"},
+		{"https://golang.org/pkg/fmt/..", "https://golang.org/pkg"},
+		{"https://golang.org//pkg//", "https://golang.org/pkg"},
+		// The root keeps its slash, with or without one written.
+		{"https://golang.org/", "https://golang.org/"},
+		{"https://golang.org", "https://golang.org/"},
+		{"https://golang.org/pkg/?b=2#frag", "https://golang.org/pkg?b=2"},
+		{"https://example.com/a%2Fb/", "https://example.com/a%2Fb"},
+		{"/a/./b/", "/a/b"},
+		{"relative/path/", "relative/path/"},
+	}
+	for _, test := range tests {
+		got, err := n.Normalize(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("Normalize(%q) = %q, %v, want %q", test.in, got, err, test.want)
+		}
+		if again, err := n.Normalize(got); err != nil || again != got {
+			t.Errorf("Normalize(%q) = %q, %v, want it unchanged", got, again, err)
+		}
+	}
+}
+
+func TestURLNormalizerError(t *testing.T) {
+	if _, err := (URLNormalizer{}).Normalize("http://[::1"); err == nil {
+		t.Error("Normalize of a bad URL returned no error")
+	}
+	// normalizeURL hands bad URLs through, so the fetcher can report them.
+	if got := normalizeURL("http://[::1"); got != "http://[::1" {
+		t.Errorf("normalizeURL = %q, want the input back", got)
+	}
+}
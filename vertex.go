@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Vector math on Point, next to abs() and scale() in methods.go. These all
+// use value receivers and return a new Point, so they chain:
+// a.Sub(b).Normalize().Scale(2). Results that need fractions, like
+// Normalize, are a Point[float64] (a Vertex) even for a Point[int].
+
+// Add returns v + w.
+func (v Point[T]) Add(w Point[T]) Point[T] {
+	return Point[T]{v.X + w.X, v.Y + w.Y}
+}
+
+// Sub returns v - w.
+func (v Point[T]) Sub(w Point[T]) Point[T] {
+	return Point[T]{v.X - w.X, v.Y - w.Y}
+}
+
+// Scale returns v times factor. Unlike scale(), it leaves v alone.
+func (v Point[T]) Scale(factor T) Point[T] {
+	return Point[T]{v.X * factor, v.Y * factor}
+}
+
+// Dot returns the dot product of v and w.
+func (v Point[T]) Dot(w Point[T]) T {
+	return v.X*w.X + v.Y*w.Y
+}
+
+// Cross returns the z component of the 3D cross product of v and w. It is
+// positive when w is counterclockwise from v, negative when clockwise, and 0
+// when they are parallel.
+func (v Point[T]) Cross(w Point[T]) T {
+	return v.X*w.Y - v.Y*w.X
+}
+
+// Normalize returns v scaled to length 1. The zero Vertex has no direction,
+// so it is returned as is.
+func (v Point[T]) Normalize() Vertex {
+	length := v.abs()
+	if length == 0 {
+		return v.Float()
+	}
+	return Vertex{float64(v.X) / length, float64(v.Y) / length}
+}
+
+// Rotate returns v rotated counterclockwise around the origin by angle,
+// in radians.
+func (v Point[T]) Rotate(angle float64) Vertex {
+	sin, cos := math.Sincos(angle)
+	x, y := float64(v.X), float64(v.Y)
+	return Vertex{x*cos - y*sin, x*sin + y*cos}
+}
+
+// Distance returns the distance between v and w.
+func (v Point[T]) Distance(w Point[T]) float64 {
+	return v.Sub(w).abs()
+}
+
+// Lerp returns the point a fraction t of the way from v to w. t = 0 gives v,
+// t = 1 gives w, and values outside [0, 1] extrapolate.
+func (v Point[T]) Lerp(w Point[T], t float64) Vertex {
+	a, b := v.Float(), w.Float()
+	return Vertex{a.X + (b.X-a.X)*t, a.Y + (b.Y-a.Y)*t}
+}
+
+// Equal reports whether v and w are within epsilon of each other on both
+// axes. Float math rarely gives exact results, so use this instead of ==.
+func (v Point[T]) Equal(w Point[T], epsilon float64) bool {
+	return math.Abs(float64(v.X-w.X)) <= epsilon && math.Abs(float64(v.Y-w.Y)) <= epsilon
+}
+
+func vectors() {
+	a, b := Vertex{1, 0}, Vertex{0, 2}
+	fmt.Println(a.Add(b), a.Sub(b), a.Dot(b), a.Cross(b))
+	fmt.Println(b.Normalize(), a.Distance(b), a.Lerp(b, 0.5))
+	// Rotating a quarter turn is only *about* b: cos(pi/2) is not exactly 0.
+	rotated := a.Rotate(math.Pi / 2).Scale(2)
+	fmt.Println(rotated, rotated == b, rotated.Equal(b, 1e-9))
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// VertexIndex is the map[string]vertex from maps(), plus a k-d tree over the
+// same points, so it can also answer "what is near here?" without looking at
+// every entry.
+//
+// A k-d tree splits space in two at every node, on x at even depths and on y
+// at odd ones. A search can then skip every subtree on the far side of a
+// split that is further away than what it already found.
+type VertexIndex struct {
+	points map[string]vertex
+	root   *kdNode
+	// deleted counts nodes still in the tree whose name was deleted.
+	deleted int
+}
+
+type kdNode struct {
+	name        string
+	v           vertex
+	left, right *kdNode
+	// deleted nodes still split space, but are never returned. Removing a
+	// node from the middle of a k-d tree means rebuilding below it, so the
+	// whole tree is rebuilt once too many are deleted instead.
+	deleted bool
+}
+
+// NewVertexIndex returns an empty index.
+func NewVertexIndex() *VertexIndex {
+	return &VertexIndex{points: make(map[string]vertex)}
+}
+
+// axisValue returns the coordinate a node at depth splits on.
+func axisValue(v vertex, depth int) int {
+	if depth%2 == 0 {
+		return v.X
+	}
+	return v.Y
+}
+
+func distSquared(a, b vertex) int {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
+
+// Insert adds name at v, or moves it there if it already exists.
+func (ix *VertexIndex) Insert(name string, v vertex) {
+	ix.Delete(name)
+	ix.points[name] = v
+	node := &kdNode{name: name, v: v}
+	link := &ix.root
+	for depth := 0; *link != nil; depth++ {
+		if axisValue(v, depth) < axisValue((*link).v, depth) {
+			link = &(*link).left
+		} else {
+			link = &(*link).right
+		}
+	}
+	*link = node
+}
+
+// Delete removes name, and reports whether it was there.
+func (ix *VertexIndex) Delete(name string) bool {
+	v, ok := ix.points[name]
+	if !ok {
+		return false
+	}
+	delete(ix.points, name)
+	// Walk down the same way Insert did, to the live node with this name.
+	node := ix.root
+	for depth := 0; node != nil; depth++ {
+		if node.name == name && !node.deleted {
+			node.deleted = true
+			ix.deleted++
+			break
+		}
+		if axisValue(v, depth) < axisValue(node.v, depth) {
+			node = node.left
+		} else {
+			node = node.right
+		}
+	}
+	if ix.deleted > len(ix.points) {
+		ix.rebuild()
+	}
+	return true
+}
+
+// Get returns the point stored for name, like m[name].
+func (ix *VertexIndex) Get(name string) (vertex, bool) {
+	v, ok := ix.points[name]
+	return v, ok
+}
+
+// Len returns the number of names in the index.
+func (ix *VertexIndex) Len() int {
+	return len(ix.points)
+}
+
+// rebuild makes a balanced tree of the live points, by splitting at the
+// median on each level.
+func (ix *VertexIndex) rebuild() {
+	nodes := make([]*kdNode, 0, len(ix.points))
+	for name, v := range ix.points {
+		nodes = append(nodes, &kdNode{name: name, v: v})
+	}
+	ix.root = buildKD(nodes, 0)
+	ix.deleted = 0
+}
+
+func buildKD(nodes []*kdNode, depth int) *kdNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := axisValue(nodes[i].v, depth), axisValue(nodes[j].v, depth)
+		if a != b {
+			return a < b
+		}
+		return nodes[i].name < nodes[j].name
+	})
+	mid := len(nodes) / 2
+	// Insert sends equal values right, so the median must be the first of
+	// its equals.
+	for mid > 0 && axisValue(nodes[mid-1].v, depth) == axisValue(nodes[mid].v, depth) {
+		mid--
+	}
+	node := nodes[mid]
+	node.left = buildKD(nodes[:mid], depth+1)
+	node.right = buildKD(nodes[mid+1:], depth+1)
+	return node
+}
+
+// Neighbor is a search result.
+type Neighbor struct {
+	Name string
+	V    vertex
+	// DistSquared is the squared distance to the query point. Squared, to
+	// stay in ints.
+	DistSquared int
+}
+
+// closer orders neighbors by distance, then name, so ties are stable.
+func closer(a, b Neighbor) bool {
+	if a.DistSquared != b.DistSquared {
+		return a.DistSquared < b.DistSquared
+	}
+	return a.Name < b.Name
+}
+
+// Nearest returns the point closest to p. It reports false if the index is
+// empty.
+func (ix *VertexIndex) Nearest(p vertex) (Neighbor, bool) {
+	best := ix.KNearest(p, 1)
+	if len(best) == 0 {
+		return Neighbor{}, false
+	}
+	return best[0], true
+}
+
+// KNearest returns the k points closest to p, closest first.
+func (ix *VertexIndex) KNearest(p vertex, k int) []Neighbor {
+	if k <= 0 {
+		return nil
+	}
+	// best is kept sorted; k is expected to be small.
+	best := make([]Neighbor, 0, k+1)
+	var search func(node *kdNode, depth int)
+	search = func(node *kdNode, depth int) {
+		if node == nil {
+			return
+		}
+		if !node.deleted {
+			candidate := Neighbor{node.name, node.v, distSquared(p, node.v)}
+			if len(best) < k || closer(candidate, best[len(best)-1]) {
+				i := sort.Search(len(best), func(i int) bool { return closer(candidate, best[i]) })
+				best = append(best, Neighbor{})
+				copy(best[i+1:], best[i:])
+				best[i] = candidate
+				if len(best) > k {
+					best = best[:k]
+				}
+			}
+		}
+		diff := axisValue(p, depth) - axisValue(node.v, depth)
+		near, far := node.left, node.right
+		if diff >= 0 {
+			near, far = far, near
+		}
+		search(near, depth+1)
+		// The far side is at least diff away, so skip it when k closer
+		// points are known already.
+		if len(best) < k || diff*diff <= best[len(best)-1].DistSquared {
+			search(far, depth+1)
+		}
+	}
+	search(ix.root, 0)
+	return best
+}
+
+// InBox returns the names of the points with min.X <= x <= max.X and
+// min.Y <= y <= max.Y, sorted.
+func (ix *VertexIndex) InBox(min, max vertex) []string {
+	var names []string
+	var search func(node *kdNode, depth int)
+	search = func(node *kdNode, depth int) {
+		if node == nil {
+			return
+		}
+		v := node.v
+		if !node.deleted && min.X <= v.X && v.X <= max.X && min.Y <= v.Y && v.Y <= max.Y {
+			names = append(names, node.name)
+		}
+		split := axisValue(v, depth)
+		// Left holds values < split, right holds values >= split.
+		if axisValue(min, depth) < split {
+			search(node.left, depth+1)
+		}
+		if axisValue(max, depth) >= split {
+			search(node.right, depth+1)
+		}
+	}
+	search(ix.root, 0)
+	sort.Strings(names)
+	return names
+}
+
+func spatialIndex() {
+	ix := NewVertexIndex()
+	for name, v := range map[string]vertex{
+		"home": {0, 0}, "school": {3, 4}, "park": {-2, 1}, "shop": {5, 5}, "gym": {1, -3},
+	} {
+		ix.Insert(name, v)
+	}
+	fmt.Println(ix.Nearest(vertex{4, 4}))
+	fmt.Println(ix.KNearest(vertex{0, 0}, 3))
+	fmt.Println(ix.InBox(vertex{-2, -1}, vertex{3, 4}))
+	// Moving and deleting keep the map and the tree in sync.
+	ix.Insert("shop", vertex{-1, 0})
+	ix.Delete("home")
+	fmt.Println(ix.Nearest(vertex{0, 0}))
+}
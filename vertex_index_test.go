@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// linearKNearest is KNearest by looking at every point, keeping the k
+// closest so far in order.
+func linearKNearest(points map[string]vertex, p vertex, k int) []Neighbor {
+	if k <= 0 {
+		return nil
+	}
+	best := make([]Neighbor, 0, k+1)
+	for name, v := range points {
+		candidate := Neighbor{name, v, distSquared(p, v)}
+		if len(best) == k && !closer(candidate, best[k-1]) {
+			continue
+		}
+		i := len(best)
+		best = append(best, candidate)
+		for ; i > 0 && closer(candidate, best[i-1]); i-- {
+			best[i] = best[i-1]
+		}
+		best[i] = candidate
+		if len(best) > k {
+			best = best[:k]
+		}
+	}
+	return best
+}
+
+// linearInBox is InBox by looking at every point.
+func linearInBox(points map[string]vertex, min, max vertex) []string {
+	var names []string
+	for name, v := range points {
+		if min.X <= v.X && v.X <= max.X && min.Y <= v.Y && v.Y <= max.Y {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// liveNodes counts the nodes in the tree that are not deleted.
+func liveNodes(node *kdNode) int {
+	if node == nil {
+		return 0
+	}
+	n := liveNodes(node.left) + liveNodes(node.right)
+	if !node.deleted {
+		n++
+	}
+	return n
+}
+
+// TestVertexIndexMatchesLinearScan runs random inserts, moves, deletes and
+// queries against both the index and a plain map, and checks every answer
+// against a linear scan of the map. Coordinates come from a small range, so
+// there are plenty of equal values and equal distances.
+func TestVertexIndexMatchesLinearScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randVertex := func() vertex {
+		return vertex{rng.Intn(41) - 20, rng.Intn(41) - 20}
+	}
+	ix := NewVertexIndex()
+	points := make(map[string]vertex)
+	for step := 0; step < 20000; step++ {
+		name := fmt.Sprint("p", rng.Intn(300))
+		switch op := rng.Intn(10); {
+		case op < 4:
+			v := randVertex()
+			ix.Insert(name, v)
+			points[name] = v
+		case op < 6:
+			_, want := points[name]
+			if got := ix.Delete(name); got != want {
+				t.Fatalf("step %d: Delete(%s) = %v, want %v", step, name, got, want)
+			}
+			delete(points, name)
+		case op < 8:
+			p, k := randVertex(), rng.Intn(8)
+			if got, want := ix.KNearest(p, k), linearKNearest(points, p, k); !reflect.DeepEqual(got, want) {
+				t.Fatalf("step %d: KNearest(%v, %d) = %v, want %v", step, p, k, got, want)
+			}
+			got, ok := ix.Nearest(p)
+			if want := linearKNearest(points, p, 1); ok != (len(want) == 1) || ok && got != want[0] {
+				t.Fatalf("step %d: Nearest(%v) = %v, %v, want %v", step, p, got, ok, want)
+			}
+		default:
+			min := randVertex()
+			max := min.Add(vertex{rng.Intn(15), rng.Intn(15)})
+			if rng.Intn(10) == 0 {
+				// An empty box, with min past max.
+				max = min.Sub(vertex{1, 1})
+			}
+			if got, want := ix.InBox(min, max), linearInBox(points, min, max); !reflect.DeepEqual(got, want) {
+				t.Fatalf("step %d: InBox(%v, %v) = %v, want %v", step, min, max, got, want)
+			}
+		}
+		if ix.Len() != len(points) {
+			t.Fatalf("step %d: Len() = %d, want %d", step, ix.Len(), len(points))
+		}
+		if live := liveNodes(ix.root); live != len(points) {
+			t.Fatalf("step %d: %d live nodes in the tree for %d points", step, live, len(points))
+		}
+		if ix.deleted > len(points) {
+			t.Fatalf("step %d: %d deleted nodes kept for %d points", step, ix.deleted, len(points))
+		}
+		want, wantOK := points[name]
+		if got, ok := ix.Get(name); got != want || ok != wantOK {
+			t.Fatalf("step %d: Get(%s) = %v, %v, want %v, %v", step, name, got, ok, want, wantOK)
+		}
+	}
+}
+
+// benchIndex returns an index and a map of the same n random points, spread
+// out so that few share a coordinate.
+func benchIndex(n int) (*VertexIndex, map[string]vertex, []vertex) {
+	rng := rand.New(rand.NewSource(1))
+	ix := NewVertexIndex()
+	points := make(map[string]vertex, n)
+	for i := 0; i < n; i++ {
+		v := vertex{rng.Intn(1e6), rng.Intn(1e6)}
+		name := fmt.Sprint(i)
+		ix.Insert(name, v)
+		points[name] = v
+	}
+	queries := make([]vertex, 1024)
+	for i := range queries {
+		queries[i] = vertex{rng.Intn(1e6), rng.Intn(1e6)}
+	}
+	return ix, points, queries
+}
+
+// BenchmarkKNearest compares the k-d tree with looking at every point. The
+// tree pays off more the more points there are.
+func BenchmarkKNearest(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		ix, points, queries := benchIndex(n)
+		for _, k := range []int{1, 10} {
+			b.Run(fmt.Sprintf("points=%d/k=%d/tree", n, k), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if len(ix.KNearest(queries[i%len(queries)], k)) != k {
+						b.Fatal("too few neighbors")
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("points=%d/k=%d/linear", n, k), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if len(linearKNearest(points, queries[i%len(queries)], k)) != k {
+						b.Fatal("too few neighbors")
+					}
+				}
+			})
+		}
+	}
+}
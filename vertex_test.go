@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+)
+
+func TestVectorArithmetic(t *testing.T) {
+	tests := []struct {
+		v, w       vertex
+		add, sub   vertex
+		dot, cross int
+	}{
+		{vertex{0, 0}, vertex{0, 0}, vertex{0, 0}, vertex{0, 0}, 0, 0},
+		{vertex{1, 0}, vertex{0, 1}, vertex{1, 1}, vertex{1, -1}, 0, 1},
+		// Clockwise the other way round.
+		{vertex{0, 1}, vertex{1, 0}, vertex{1, 1}, vertex{-1, 1}, 0, -1},
+		// Parallel.
+		{vertex{2, 4}, vertex{-1, -2}, vertex{1, 2}, vertex{3, 6}, -10, 0},
+		{vertex{3, -4}, vertex{5, 2}, vertex{8, -2}, vertex{-2, -6}, 7, 26},
+	}
+	for _, test := range tests {
+		if got := test.v.Add(test.w); got != test.add {
+			t.Errorf("%v.Add(%v) = %v, want %v", test.v, test.w, got, test.add)
+		}
+		if got := test.v.Sub(test.w); got != test.sub {
+			t.Errorf("%v.Sub(%v) = %v, want %v", test.v, test.w, got, test.sub)
+		}
+		if got := test.v.Dot(test.w); got != test.dot {
+			t.Errorf("%v.Dot(%v) = %v, want %v", test.v, test.w, got, test.dot)
+		}
+		if got := test.v.Cross(test.w); got != test.cross {
+			t.Errorf("%v.Cross(%v) = %v, want %v", test.v, test.w, got, test.cross)
+		}
+	}
+	v := vertex{3, -4}
+	if got := v.Scale(-2); got != (vertex{-6, 8}) {
+		t.Errorf("%v.Scale(-2) = %v", v, got)
+	}
+	if v != (vertex{3, -4}) {
+		t.Errorf("Scale changed v to %v", v)
+	}
+}
+
+func TestVectorFloatResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want Vertex
+	}{
+		{"Normalize", vertex{3, 4}.Normalize(), Vertex{0.6, 0.8}},
+		{"Normalize of a negative", Vertex{0, -2}.Normalize(), Vertex{0, -1}},
+		{"Normalize of zero", vertex{0, 0}.Normalize(), Vertex{0, 0}},
+		{"Rotate a quarter turn", Vertex{1, 0}.Rotate(math.Pi / 2), Vertex{0, 1}},
+		{"Rotate a half turn", vertex{3, 4}.Rotate(math.Pi), Vertex{-3, -4}},
+		{"Rotate backwards", Vertex{0, 1}.Rotate(-math.Pi / 2), Vertex{1, 0}},
+		{"Lerp start", vertex{0, 0}.Lerp(vertex{10, 20}, 0), Vertex{0, 0}},
+		{"Lerp middle", vertex{0, 0}.Lerp(vertex{10, 20}, 0.5), Vertex{5, 10}},
+		{"Lerp end", vertex{0, 0}.Lerp(vertex{10, 20}, 1), Vertex{10, 20}},
+		{"Lerp beyond", vertex{0, 0}.Lerp(vertex{10, 20}, 1.5), Vertex{15, 30}},
+		{"Lerp before", Vertex{1, 1}.Lerp(Vertex{2, 3}, -1), Vertex{0, -1}},
+	}
+	for _, test := range tests {
+		if !test.got.Equal(test.want, 1e-12) {
+			t.Errorf("%s = %v, want %v", test.name, test.got, test.want)
+		}
+	}
+	if got := (vertex{1, 1}).Distance(vertex{4, 5}); got != 5 {
+		t.Errorf("Distance = %v, want 5", got)
+	}
+}
+
+func TestVectorEqual(t *testing.T) {
+	tests := []struct {
+		v, w    Vertex
+		epsilon float64
+		want    bool
+	}{
+		{Vertex{1, 2}, Vertex{1, 2}, 0, true},
+		{Vertex{1, 2}, Vertex{1, 2.1}, 0, false},
+		{Vertex{1, 2}, Vertex{1, 2.1}, 0.2, true},
+		// Both axes must be close, not just the distance.
+		{Vertex{1, 2}, Vertex{1.1, 2.1}, 0.1 + 1e-12, true},
+		{Vertex{1, 2}, Vertex{1.2, 2}, 0.1, false},
+		{Vertex{0.1 + 0.2, 0}, Vertex{0.3, 0}, 1e-15, true},
+	}
+	for _, test := range tests {
+		if got := test.v.Equal(test.w, test.epsilon); got != test.want {
+			t.Errorf("%v.Equal(%v, %v) = %v, want %v", test.v, test.w, test.epsilon, got, test.want)
+		}
+	}
+}
+
+// The properties take int16 coordinates so the float math stays exact
+// enough to compare with a small epsilon.
+func small(x, y int16) Vertex {
+	return Vertex{float64(x), float64(y)}
+}
+
+func TestVectorProperties(t *testing.T) {
+	properties := map[string]any{
+		"Add and Sub undo each other": func(a, b vertex) bool {
+			return a.Add(b).Sub(b) == a
+		},
+		"Add commutes": func(a, b vertex) bool {
+			return a.Add(b) == b.Add(a)
+		},
+		"Dot is symmetric": func(a, b vertex) bool {
+			return a.Dot(b) == b.Dot(a)
+		},
+		"Cross is antisymmetric": func(a, b vertex) bool {
+			return a.Cross(b) == -b.Cross(a)
+		},
+		"Cross of parallel vectors is 0": func(a vertex, k int8) bool {
+			return a.Cross(a.Scale(int(k))) == 0
+		},
+		"Scale distributes over Add": func(a, b vertex, k int8) bool {
+			return a.Add(b).Scale(int(k)) == a.Scale(int(k)).Add(b.Scale(int(k)))
+		},
+		"Normalize has length 1": func(x, y int16) bool {
+			v := small(x, y)
+			if v == (Vertex{}) {
+				return v.Normalize() == v
+			}
+			return math.Abs(v.Normalize().abs()-1) < 1e-12
+		},
+		"Rotate keeps the length": func(x, y int16, angle float64) bool {
+			v := small(x, y)
+			return math.Abs(v.Rotate(math.Mod(angle, 10)).abs()-v.abs()) < 1e-9
+		},
+		"Rotate back is where it started": func(x, y int16, angle float64) bool {
+			v := small(x, y)
+			angle = math.Mod(angle, 10)
+			return v.Rotate(angle).Rotate(-angle).Equal(v, 1e-9)
+		},
+		"Distance is symmetric": func(ax, ay, bx, by int16) bool {
+			a, b := small(ax, ay), small(bx, by)
+			return a.Distance(b) == b.Distance(a)
+		},
+		"Distance obeys the triangle inequality": func(ax, ay, bx, by, cx, cy int16) bool {
+			a, b, c := small(ax, ay), small(bx, by), small(cx, cy)
+			return a.Distance(c) <= a.Distance(b)+b.Distance(c)+1e-9
+		},
+		"Lerp stays on the segment": func(ax, ay, bx, by int16, t uint16) bool {
+			a, b := small(ax, ay), small(bx, by)
+			f := float64(t) / math.MaxUint16
+			p := a.Lerp(b, f)
+			return math.Abs(a.Distance(p)+p.Distance(b)-a.Distance(b)) < 1e-9
+		},
+	}
+	for name, property := range properties {
+		if err := quick.Check(property, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
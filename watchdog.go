@@ -0,0 +1,141 @@
+package main
+
+import (
+	"./tree"
+	"bytes"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// The comments in ConcurrencyMain warn that a send nobody reads deadlocks.
+// The runtime only notices when *every* goroutine is stuck; one stuck
+// goroutine among running ones just hangs, or leaks quietly. Watch catches
+// both, so a demo can be checked from `go test` (return its error to
+// t.Fatal) or with `./driver.exe -check`.
+
+// leakGrace is how long Watch waits for goroutines that are still on their
+// way out, e.g. a talker that was told to quit but hasn't returned yet.
+const leakGrace = 500 * time.Millisecond
+
+var goroutineHeader = regexp.MustCompile(`^goroutine (\d+) `)
+
+// goroutineStacks returns the stack of every goroutine, by goroutine id.
+func goroutineStacks() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := make(map[string]string)
+	// Stacks are separated by a blank line, and start with "goroutine N [".
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if match := goroutineHeader.FindSubmatch(stack); match != nil {
+			stacks[string(match[1])] = string(stack)
+		}
+	}
+	return stacks
+}
+
+// Watch runs fn and returns an error if it did not return within timeout,
+// with every goroutine's stack to show where it is stuck, or if it left
+// goroutines behind, with their stacks.
+//
+// A stalled fn can't be stopped, it keeps running in the background.
+func Watch(name string, timeout time.Duration, fn func()) error {
+	before := goroutineStacks()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		var dump strings.Builder
+		for _, stack := range goroutineStacks() {
+			dump.WriteString(stack + "\n\n")
+		}
+		return fmt.Errorf("%s: stalled for %v, goroutines:\n%s", name, timeout, dump.String())
+	}
+
+	var leaked []string
+	for deadline := time.Now().Add(leakGrace); ; {
+		leaked = leaked[:0]
+		for id, stack := range goroutineStacks() {
+			if _, ok := before[id]; !ok {
+				leaked = append(leaked, stack)
+			}
+		}
+		if len(leaked) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("%s: leaked %d goroutines:\n%s", name, len(leaked), strings.Join(leaked, "\n\n"))
+}
+
+// watchedDemo is a demo for CheckDemos.
+type watchedDemo struct {
+	name string
+	fn   func()
+	// leaks is true for demos known to leave goroutines behind.
+	leaks bool
+}
+
+var watchedDemos = []watchedDemo{
+	{name: "channelSelect", fn: func() {
+		dataChan, quitChan := make(chan int), make(chan int)
+		go func() {
+			for i := 0; i < 5; i++ {
+				<-dataChan
+			}
+			quitChan <- 0
+		}()
+		channelSelect(dataChan, quitChan)
+	}},
+	{name: "TalkingGophers", fn: TalkingGophers},
+	{name: "crawl", fn: func() {
+		crawl("https://golang.org/", 4, FakeFetcherImpl, &SafeCache{})
+	}},
+	{name: "SameInOrderTraversal", fn: func() {
+		SameInOrderTraversal(tree.New(1), tree.New(1))
+	}},
+	// On the first difference it returns without draining the channels, so
+	// both Walk goroutines stay blocked on a send. Kept to show the check
+	// catches it.
+	{name: "SameInOrderTraversal of different trees", leaks: true, fn: func() {
+		SameInOrderTraversal(tree.New(1), tree.New(2))
+	}},
+}
+
+// CheckDemos runs the concurrency demos under Watch, and reports whether
+// each one behaved as expected.
+func CheckDemos(timeout time.Duration) bool {
+	ok := true
+	for _, demo := range watchedDemos {
+		err := Watch(demo.name, timeout, demo.fn)
+		switch {
+		case err == nil && !demo.leaks:
+			fmt.Println("ok:", demo.name)
+		case err != nil && demo.leaks && strings.Contains(err.Error(), "leaked"):
+			fmt.Println("ok, leaked as expected:", demo.name)
+		case err == nil:
+			fmt.Println("FAIL, expected a leak:", demo.name)
+			ok = false
+		default:
+			fmt.Println("FAIL:", err)
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWatchedDemos runs every demo CheckDemos runs, as its own subtest.
+// TalkingGophers alone takes about 10s, more with -race, so it is skipped
+// with -short.
+func TestWatchedDemos(t *testing.T) {
+	for _, demo := range watchedDemos {
+		t.Run(demo.name, func(t *testing.T) {
+			if testing.Short() && demo.name == "TalkingGophers" {
+				t.Skip("slow")
+			}
+			err := Watch(demo.name, time.Minute, demo.fn)
+			if !demo.leaks {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "leaked") {
+				t.Fatalf("Watch = %v, want a leak", err)
+			}
+		})
+	}
+}